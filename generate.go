@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/connector"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/zwave2mqtt"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/zwavejs2mqtt"
+)
+
+// generateDeviceTypes writes the device types for the nodes of a recorded getNodes response to <output>/<mapping-key>.json
+func generateDeviceTypes(args []string) error {
+	flags := flag.NewFlagSet("generate-device-types", flag.ExitOnError)
+	configLocation := flags.String("config", "config.json", "configuration file")
+	input := flags.String("input", "", "recorded getNodes response")
+	output := flags.String("output", "device-types", "output directory")
+	controller := flags.String("controller", "", "format of the getNodes response: zwavejs2mqtt or zwave2mqtt (default: zwave_controller of the configuration)")
+	err := flags.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *input == "" {
+		return errors.New("missing -input")
+	}
+
+	config, err := configuration.Load(*configLocation)
+	if err != nil {
+		return err
+	}
+	if *controller == "" {
+		*controller = config.ZwaveController
+	}
+
+	payload, err := os.ReadFile(*input)
+	if err != nil {
+		return err
+	}
+	var nodes []model.DeviceInfo
+	switch *controller {
+	case "zwavejs2mqtt":
+		nodes, _, _, err = zwavejs2mqtt.ParseGetNodesResult(payload)
+	case "zwave2mqtt":
+		nodes, _, err = zwave2mqtt.ParseGetNodesResult(payload)
+	default:
+		err = fmt.Errorf("unknown controller format %q", *controller)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(*output, 0755)
+	if err != nil {
+		return err
+	}
+	deviceTypes := connector.GenerateDeviceTypes(config, nodes)
+	keys := []string{}
+	for key := range deviceTypes {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		temp, err := json.MarshalIndent(deviceTypes[key], "", "    ")
+		if err != nil {
+			return err
+		}
+		file := filepath.Join(*output, key+".json")
+		err = os.WriteFile(file, temp, 0644)
+		if err != nil {
+			return err
+		}
+		config.GetLogger().Info("generated device type", "file", file, "name", deviceTypes[key].Name)
+	}
+	return nil
+}
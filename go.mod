@@ -4,9 +4,11 @@ go 1.25.0
 
 require (
 	github.com/SENERGY-Platform/device-repository v0.2.43
+	github.com/SENERGY-Platform/go-service-base/struct-logger v0.6.0
 	github.com/SENERGY-Platform/models/go v0.0.0-20260302084452-04ca9ee69c93
 	github.com/eclipse/paho.mqtt.golang v1.4.3
 	github.com/testcontainers/testcontainers-go v0.40.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -15,7 +17,6 @@ require (
 	github.com/KyleBanks/depth v1.2.1 // indirect
 	github.com/Microsoft/go-winio v0.6.2 // indirect
 	github.com/SENERGY-Platform/developer-notifications v0.0.5 // indirect
-	github.com/SENERGY-Platform/go-service-base/struct-logger v0.6.0 // indirect
 	github.com/SENERGY-Platform/permissions-v2 v0.0.42 // indirect
 	github.com/SENERGY-Platform/service-commons v0.0.0-20260507090252-155b04bb4c46 // indirect
 	github.com/cenkalti/backoff/v4 v4.3.0 // indirect
@@ -96,5 +97,4 @@ require (
 	golang.org/x/sys v0.41.0 // indirect
 	golang.org/x/text v0.34.0 // indirect
 	golang.org/x/tools v0.42.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
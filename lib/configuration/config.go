@@ -47,14 +47,43 @@ type Config struct {
 	ZvaveValueEventTopic         string            `json:"zvave_value_event_topic"`       //used in zwave2mqtt
 	ZwaveMqttApiTopic            string            `json:"zwave_mqtt_api_topic"`
 	ZwaveNetworkEventsTopic      string            `json:"zwave_network_events_topic"`
+	ZwaveControllerEventsTopic   string            `json:"zwave_controller_events_topic"` //used in zwavejs2mqtt
+	ZwaveNodeEventsTopic         string            `json:"zwave_node_events_topic"`       //used in zwavejs2mqtt
+	ZwaveApiTimeout              Duration          `json:"zwave_api_timeout"`
 	UpdatePeriod                 string            `json:"update_period"`
 	InitialUpdateRequestDelay    Duration          `json:"initial_update_request_delay"`
 	Debug                        bool              `json:"debug"`
 	DeviceTypeMapping            map[string]string `json:"device_type_mapping"`
 	DeleteMissingDevices         bool              `json:"delete_missing_devices"`
 	DeleteHusks                  bool              `json:"delete_husks"`
+	HuskRecoveryAttempts         int               `json:"husk_recovery_attempts"` //if > 0, husks are re-interviewed up to n times before delete_husks applies
+	HuskRecoveryBackoff          Duration          `json:"husk_recovery_backoff"`  //wait time after the first re-interview, doubled per attempt; default 10m
 	EventsForUnregisteredDevices bool              `json:"events_for_unregistered_devices"`
 	NodeDeviceTypeOverwrite      map[string]string `json:"node_device_type_overwrite"`
+	NameSync                     bool              `json:"name_sync"`                 //pushes mgw names of nodes to the gateway if the gateway has no name or loses a conflict
+	NameConflictWinner           string            `json:"name_conflict_winner"`      //"gateway" (default) or "mgw"
+	DeviceNameTemplate           string            `json:"device_name_template"`      //go text/template, e.g. {{if .Location}}{{.Location}} – {{end}}{{or .Name .Product}}
+	ControllerDeviceTypeId       string            `json:"controller_device_type_id"` //if set, each controller is registered as virtual device <prefix>:controller
+	TopologyRefreshInterval      Duration          `json:"topology_refresh_interval"` //if set, the neighbor lists are refreshed periodically; needs controller_device_type_id
+	FirmwareUpdateTimeout        Duration          `json:"firmware_update_timeout"`   //max duration of a firmware update without finish event; default 1h
+	FirmwareDirectory            string            `json:"firmware_directory"`        //local firmware files have to be in this directory; empty: only http(s) urls
+	FirmwareMaxSize              int64             `json:"firmware_max_size"`         //bytes; default 16 MiB
+
+	ConfigParameterProfiles map[string][]ConfigParameterSetting `json:"config_parameter_profiles"` //keyed by type mapping key
+
+	SplitEndpoints []string `json:"split_endpoints"` //mapping keys or device type ids of nodes whose multi-channel endpoints are registered as separate devices
+
+	Controllers []ControllerConfig `json:"controllers" config:"secret"` //if empty, the zwave_* fields above describe the only controller
+
+	DeviceGroups []DeviceGroupConfig `json:"device_groups"` //registered as virtual devices which write to all members at once
+
+	PollingRules   []PollingRule `json:"polling_rules"`   //values of nodes which do not report on their own
+	PollingTimeout Duration      `json:"polling_timeout"` //time a poll waits for the gateway response; default 5s
+
+	Filters Filters `json:"filters"` //nodes and values exposed to the mgw
+
+	ServiceIdTransition bool                 `json:"service_id_transition"` //zwavejs2mqtt values are additionally sent and accepted under their zwave2mqtt service ids
+	ServiceIdMigrations []ServiceIdMigration `json:"service_id_migrations"` //extends the built-in zwave2mqtt to zwavejs2mqtt table
 
 	AuthEndpoint             string  `json:"auth_endpoint"`
 	AuthClientId             string  `json:"auth_client_id" config:"secret"`
@@ -67,6 +96,15 @@ type Config struct {
 	MinCacheDuration    string `json:"min_cache_duration"`
 	MaxCacheDuration    string `json:"max_cache_duration"`
 
+	DeviceRepositoryTimeout          Duration    `json:"device_repository_timeout"`           //per request; default 30s
+	DeviceRepositoryRetries          OptionalInt `json:"device_repository_retries"`           //retries of failed requests (network errors, 429, 5xx); default 3 if unset, 0 or negative disables retries
+	DeviceRepositoryRetryBackoff     Duration    `json:"device_repository_retry_backoff"`     //wait time before the first retry, doubled per retry and jittered; default 1s
+	DeviceRepositoryBreakerThreshold int         `json:"device_repository_breaker_threshold"` //consecutive failures which open the circuit breaker; default 5
+	DeviceRepositoryBreakerCooldown  Duration    `json:"device_repository_breaker_cooldown"`  //time until an open circuit breaker lets a trial request pass; default 1m
+
+	DeviceRepositoryPageSize     int  `json:"device_repository_page_size"`       //device types per list request; default 100
+	DeviceTypeLookupByMappingKey bool `json:"device_type_lookup_by_mapping_key"` //load only device types with the mapping keys of known nodes instead of all z-wave device types
+
 	CreateMissingDeviceTypes                         bool   `json:"create_missing_device_types"`
 	CreateMissingDeviceTypesWithDeviceClass          string `json:"create_missing_device_types_with_device_class"`
 	CreateMissingDeviceTypesWithProtocol             string `json:"create_missing_device_types_with_protocol"`
@@ -74,10 +112,181 @@ type Config struct {
 	CreateMissingDeviceTypesLastUpdateFunction       string `json:"create_missing_device_types_last_update_function"`
 	CreateMissingDeviceTypesLastUpdateCharacteristic string `json:"create_missing_device_types_last_update_characteristic"`
 
+	SyncDeviceTypes bool `json:"sync_device_types"` //report services missing in the matched device type; device types created by the connector are updated
+
+	DeviceTypeRules []DeviceTypeRule `json:"device_type_rules"` //evaluated after node_device_type_overwrite and device_type_mapping
+
+	DeviceTypeMappingFile              string   `json:"device_type_mapping_file"`                //json or yaml file with device_type_mapping and node_device_type_overwrite; entries overwrite the main config
+	DeviceTypeMappingFileCheckInterval Duration `json:"device_type_mapping_file_check_interval"` //default 10s
+
+	SemanticRules []SemanticRule    `json:"semantic_rules"` //evaluated before the built-in rules
+	SemanticIds   map[string]string `json:"semantic_ids"`   //resolves the names used in semantic rules (e.g. "celsius") to platform ids
+
 	LogLevel string       `json:"log_level"`
 	logger   *slog.Logger `json:"-"`
 }
 
+// ControllerConfig describes one z-wave gateway; empty zwave_controller, zwave_mqtt_broker (with user and pw) and zwave_mqtt_client_id
+// are taken from the main config. Topics are never taken from the main config: controllers may share a broker.
+type ControllerConfig struct {
+	DeviceIdPrefix             string `json:"device_id_prefix"`
+	ZwaveController            string `json:"zwave_controller"`
+	ZwaveMqttBroker            string `json:"zwave_mqtt_broker"`
+	ZwaveMqttUser              string `json:"zwave_mqtt_user" config:"secret"`
+	ZwaveMqttPw                string `json:"zwave_mqtt_pw" config:"secret"`
+	ZwaveMqttClientId          string `json:"zwave_mqtt_client_id"`
+	ZwaveMqttDeviceStateTopic  string `json:"zwave_mqtt_device_state_topic"` //used in zwavejs2mqtt
+	ZvaveValueEventTopic       string `json:"zvave_value_event_topic"`       //used in zwave2mqtt
+	ZwaveMqttApiTopic          string `json:"zwave_mqtt_api_topic"`
+	ZwaveNetworkEventsTopic    string `json:"zwave_network_events_topic"`
+	ZwaveControllerEventsTopic string `json:"zwave_controller_events_topic"` //used in zwavejs2mqtt
+	ZwaveNodeEventsTopic       string `json:"zwave_node_events_topic"`       //used in zwavejs2mqtt
+}
+
+// GetControllers returns the configured controllers or, if Controllers is empty, the legacy single controller
+func (this Config) GetControllers() (result []ControllerConfig) {
+	if len(this.Controllers) == 0 {
+		return []ControllerConfig{{
+			DeviceIdPrefix:             this.DeviceIdPrefix,
+			ZwaveController:            this.ZwaveController,
+			ZwaveMqttBroker:            this.ZwaveMqttBroker,
+			ZwaveMqttUser:              this.ZwaveMqttUser,
+			ZwaveMqttPw:                this.ZwaveMqttPw,
+			ZwaveMqttClientId:          this.ZwaveMqttClientId,
+			ZwaveMqttDeviceStateTopic:  this.ZwaveMqttDeviceStateTopic,
+			ZvaveValueEventTopic:       this.ZvaveValueEventTopic,
+			ZwaveMqttApiTopic:          this.ZwaveMqttApiTopic,
+			ZwaveNetworkEventsTopic:    this.ZwaveNetworkEventsTopic,
+			ZwaveControllerEventsTopic: this.ZwaveControllerEventsTopic,
+			ZwaveNodeEventsTopic:       this.ZwaveNodeEventsTopic,
+		}}
+	}
+	for _, controller := range this.Controllers {
+		if controller.ZwaveController == "" {
+			controller.ZwaveController = this.ZwaveController
+		}
+		if controller.ZwaveMqttBroker == "" {
+			controller.ZwaveMqttBroker = this.ZwaveMqttBroker
+			controller.ZwaveMqttUser = this.ZwaveMqttUser
+			controller.ZwaveMqttPw = this.ZwaveMqttPw
+		}
+		if controller.ZwaveMqttClientId == "" {
+			//controllers may share a broker; the client id has to be unique per connection
+			controller.ZwaveMqttClientId = this.ZwaveMqttClientId + "_" + controller.DeviceIdPrefix
+		}
+		result = append(result, controller)
+	}
+	return result
+}
+
+// MissingTopics returns the json names of the empty topics the controller can't work without;
+// the network, controller and node event topics are optional ("" or "-" disables them)
+func (this ControllerConfig) MissingTopics() (result []string) {
+	if this.ZwaveMqttApiTopic == "" {
+		result = append(result, "zwave_mqtt_api_topic")
+	}
+	if this.ZwaveController == "zwavejs2mqtt" && this.ZwaveMqttDeviceStateTopic == "" {
+		result = append(result, "zwave_mqtt_device_state_topic")
+	}
+	if this.ZwaveController != "zwavejs2mqtt" && this.ZvaveValueEventTopic == "" {
+		result = append(result, "zvave_value_event_topic")
+	}
+	return result
+}
+
+// ForController returns a copy of the config in which the zwave connection fields are replaced by the controller fields
+func (this Config) ForController(controller ControllerConfig) Config {
+	this.DeviceIdPrefix = controller.DeviceIdPrefix
+	this.ZwaveController = controller.ZwaveController
+	this.ZwaveMqttBroker = controller.ZwaveMqttBroker
+	this.ZwaveMqttUser = controller.ZwaveMqttUser
+	this.ZwaveMqttPw = controller.ZwaveMqttPw
+	this.ZwaveMqttClientId = controller.ZwaveMqttClientId
+	this.ZwaveMqttDeviceStateTopic = controller.ZwaveMqttDeviceStateTopic
+	this.ZvaveValueEventTopic = controller.ZvaveValueEventTopic
+	this.ZwaveMqttApiTopic = controller.ZwaveMqttApiTopic
+	this.ZwaveNetworkEventsTopic = controller.ZwaveNetworkEventsTopic
+	this.ZwaveControllerEventsTopic = controller.ZwaveControllerEventsTopic
+	this.ZwaveNodeEventsTopic = controller.ZwaveNodeEventsTopic
+	this.Controllers = nil
+	return this
+}
+
+// DeviceGroupConfig describes a group of nodes of one controller, registered as virtual device <prefix>:group:<name>
+type DeviceGroupConfig struct {
+	Name           string  `json:"name"`
+	DeviceIdPrefix string  `json:"device_id_prefix"` //controller of the group; empty for the first controller
+	NodeIds        []int64 `json:"node_ids"`
+	Broadcast      bool    `json:"broadcast"`      //writes to all nodes of the controller; node_ids is ignored
+	DeviceTypeId   string  `json:"device_type_id"` //empty: device type of the first registered member
+}
+
+// DeviceTypeRule maps matching nodes to a device type; rules are evaluated by descending priority, equal priorities in config order.
+// Patterns are "," separated alternatives of "*" (any), values ("0x010f", "271"), inclusive ranges ("0x0600-0x06ff", "1.2-2.0") and prefixes ("1.*").
+// Empty patterns match every value, except for endpoint which defaults to "0" (the root device).
+type DeviceTypeRule struct {
+	Priority        int     `json:"priority"`
+	Manufacturer    string  `json:"manufacturer"`
+	ProductType     string  `json:"product_type"`
+	ProductId       string  `json:"product_id"`
+	FirmwareVersion string  `json:"firmware_version"`
+	Endpoint        string  `json:"endpoint"`
+	EndpointCount   string  `json:"endpoint_count"`  //multi-channel endpoints of the node
+	CommandClasses  []int64 `json:"command_classes"` //all must be present in the values of the device
+	DeviceTypeId    string  `json:"device_type_id"`
+}
+
+// SemanticRule annotates services of created device types; empty match fields match every value.
+// Functions, aspects and characteristics are names resolved by semantic_ids or platform ids (urn:...)
+type SemanticRule struct {
+	CommandClass        int64  `json:"command_class"`
+	Property            string `json:"property"`
+	PropertyKey         string `json:"property_key"`
+	Unit                string `json:"unit"`
+	MeasuringFunction   string `json:"measuring_function"`   //used for get services
+	ControllingFunction string `json:"controlling_function"` //used for set services
+	Aspect              string `json:"aspect"`
+	Characteristic      string `json:"characteristic"`
+}
+
+// ServiceIdMigration maps a zwave2mqtt value (<class>-<instance>-<index>) to a zwavejs2mqtt value (<cc>-<endpoint>-<property>[-<propertyKey>]);
+// instances are mapped to endpoints (endpoint = instance - 1)
+type ServiceIdMigration struct {
+	CommandClass   int64  `json:"command_class"`
+	Index          int64  `json:"index"`
+	Property       string `json:"property"`        //<property>[-<propertyKey>] of the reported value
+	TargetProperty string `json:"target_property"` //used for set commands if the value is written by another property (e.g. targetValue)
+}
+
+// Filters decide which nodes and values are exposed; deny rules take precedence over allow rules
+type Filters struct {
+	Allow []FilterRule `json:"allow"`
+	Deny  []FilterRule `json:"deny"`
+}
+
+// FilterRule matches if all non-empty fields match; rules without command_classes and properties are node rules
+type FilterRule struct {
+	NodeIds        []int64  `json:"node_ids"`
+	MappingKeys    []string `json:"mapping_keys"`
+	CommandClasses []int64  `json:"command_classes"`
+	Properties     []string `json:"properties"`
+}
+
+// PollingRule selects values which are polled periodically; empty filters match all nodes/values
+type PollingRule struct {
+	DeviceTypeId string   `json:"device_type_id"`
+	MappingKey   string   `json:"mapping_key"`
+	ServiceIds   []string `json:"service_ids"` //without ':get' suffix, e.g. 50-0-value-65537; empty: all readable values
+	Interval     Duration `json:"interval"`
+}
+
+// ConfigParameterSetting is one entry of a configuration parameter (CC 112) profile
+type ConfigParameterSetting struct {
+	Parameter int64       `json:"parameter"`
+	BitMask   *int64      `json:"bitMask,omitempty"` //for partial parameters
+	Value     interface{} `json:"value"`
+}
+
 func (this Config) AuthEnabled() bool {
 	return this.AuthEndpoint != "" && this.AuthEndpoint != "-"
 }
@@ -165,14 +374,24 @@ func handleEnvironmentVars(config *Config, getEnv func(key string) string) (err
 				}
 				configValue.FieldByName(fieldName).SetFloat(f)
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Slice {
+			if configValue.FieldByName(fieldName).Kind() == reflect.Slice && configValue.FieldByName(fieldName).Type().Elem().Kind() != reflect.String {
+				err = json.Unmarshal([]byte(envValue), configValue.FieldByName(fieldName).Addr().Interface())
+				if err != nil {
+					return fmt.Errorf("invalid env variable %v=%v: %w", envName, envValue, err)
+				}
+			} else if configValue.FieldByName(fieldName).Kind() == reflect.Slice {
 				val := []string{}
 				for _, element := range strings.Split(envValue, ",") {
 					val = append(val, strings.TrimSpace(element))
 				}
 				configValue.FieldByName(fieldName).Set(reflect.ValueOf(val))
 			}
-			if configValue.FieldByName(fieldName).Kind() == reflect.Map {
+			if configValue.FieldByName(fieldName).Kind() == reflect.Map && configValue.FieldByName(fieldName).Type().Elem().Kind() != reflect.String {
+				err = json.Unmarshal([]byte(envValue), configValue.FieldByName(fieldName).Addr().Interface())
+				if err != nil {
+					return fmt.Errorf("invalid env variable %v=%v: %w", envName, envValue, err)
+				}
+			} else if configValue.FieldByName(fieldName).Kind() == reflect.Map {
 				value := map[string]string{}
 				for _, element := range strings.Split(envValue, ",") {
 					keyVal := strings.Split(element, ":")
@@ -220,6 +439,47 @@ func (this *Duration) UnmarshalJSON(bytes []byte) (err error) {
 	return this.SetString(str)
 }
 
+// OptionalInt distinguishes an unset value from 0
+type OptionalInt struct {
+	value int
+	set   bool
+}
+
+// Get returns the value or defaultValue if unset
+func (this *OptionalInt) Get(defaultValue int) int {
+	if !this.set {
+		return defaultValue
+	}
+	return this.value
+}
+
+func (this *OptionalInt) SetInt(value int) {
+	this.value = value
+	this.set = true
+}
+
+func (this *OptionalInt) SetString(str string) error {
+	if str == "" {
+		return nil
+	}
+	value, err := strconv.Atoi(str)
+	if err != nil {
+		return err
+	}
+	this.SetInt(value)
+	return nil
+}
+
+func (this *OptionalInt) UnmarshalJSON(bytes []byte) (err error) {
+	var value *int
+	err = json.Unmarshal(bytes, &value)
+	if err != nil || value == nil {
+		return err
+	}
+	this.SetInt(*value)
+	return nil
+}
+
 func (this *Config) GetLogger() *slog.Logger {
 	if this.logger == nil {
 		if this.Debug {
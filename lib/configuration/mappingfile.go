@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package configuration
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DeviceTypeMappingFile is the content of device_type_mapping_file
+type DeviceTypeMappingFile struct {
+	DeviceTypeMapping       map[string]string `json:"device_type_mapping" yaml:"device_type_mapping"`
+	NodeDeviceTypeOverwrite map[string]string `json:"node_device_type_overwrite" yaml:"node_device_type_overwrite"`
+}
+
+// LoadDeviceTypeMappingFile parses yaml (.yaml, .yml) or json files; returns the raw file content to detect changes
+func LoadDeviceTypeMappingFile(location string) (result DeviceTypeMappingFile, content []byte, err error) {
+	content, err = os.ReadFile(location)
+	if err != nil {
+		return result, nil, err
+	}
+	switch strings.ToLower(filepath.Ext(location)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &result)
+	default:
+		err = json.Unmarshal(content, &result)
+	}
+	return result, content, err
+}
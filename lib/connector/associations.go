@@ -0,0 +1,193 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+type AssociationGroupInfo struct {
+	model.AssociationGroup
+	Members []model.AssociationAddress `json:"members"`
+}
+
+type AssociationInput struct {
+	Endpoint int64                      `json:"endpoint"` //source endpoint
+	GroupId  int64                      `json:"groupId"`
+	Targets  []model.AssociationAddress `json:"targets"`
+	Repair   bool                       `json:"repair"` //used by checkLifeline: adds missing controller associations
+}
+
+type LifelineCheckResult struct {
+	Ok     bool                  `json:"ok"`
+	Groups []LifelineGroupResult `json:"groups"`
+}
+
+type LifelineGroupResult struct {
+	GroupId  int64 `json:"groupId"`
+	Endpoint int64 `json:"endpoint"`
+	Ok       bool  `json:"ok"`
+	Repaired bool  `json:"repaired"`
+}
+
+func parseAssociationInput(data string) (input AssociationInput, err error) {
+	if data == "" {
+		return input, nil
+	}
+	err = json.Unmarshal([]byte(data), &input)
+	if err != nil {
+		return input, fmt.Errorf("unable to unmarshal command data: %w", err)
+	}
+	return input, nil
+}
+
+func (this *Connector) listAssociationsCommand(controller *Controller, _ string, nodeId int64, _ string) (result interface{}, err error) {
+	node, _ := this.nodeStoreGet(controller, controller.nodeIdToDeviceId(nodeId))
+	associations, err := controller.client.GetAssociations(nodeId)
+	if err != nil {
+		return nil, err
+	}
+	return getAssociationGroups(node.Groups, associations), nil
+}
+
+func (this *Connector) addAssociationsCommand(controller *Controller, deviceId string, nodeId int64, data string) (result interface{}, err error) {
+	input, err := parseAssociationInput(data)
+	if err != nil {
+		return nil, err
+	}
+	if input.GroupId == 0 || len(input.Targets) == 0 {
+		return nil, errors.New("missing groupId or targets")
+	}
+	return controller.client.AddAssociations(associationSource(nodeId, getSourceEndpoint(controller, deviceId, input)), input.GroupId, input.Targets)
+}
+
+func (this *Connector) removeAssociationsCommand(controller *Controller, deviceId string, nodeId int64, data string) (result interface{}, err error) {
+	input, err := parseAssociationInput(data)
+	if err != nil {
+		return nil, err
+	}
+	if input.GroupId == 0 || len(input.Targets) == 0 {
+		return nil, errors.New("missing groupId or targets")
+	}
+	return controller.client.RemoveAssociations(associationSource(nodeId, getSourceEndpoint(controller, deviceId, input)), input.GroupId, input.Targets)
+}
+
+// checks that every lifeline group contains the controller; with repair the controller is added to groups where it is missing
+func (this *Connector) checkLifelineCommand(controller *Controller, _ string, nodeId int64, data string) (result interface{}, err error) {
+	input, err := parseAssociationInput(data)
+	if err != nil {
+		return nil, err
+	}
+	node, _ := this.nodeStoreGet(controller, controller.nodeIdToDeviceId(nodeId))
+	associations, err := controller.client.GetAssociations(nodeId)
+	if err != nil {
+		return nil, err
+	}
+	controllerNodeId := controller.getControllerNodeId()
+	check := checkLifeline(getAssociationGroups(node.Groups, associations), controllerNodeId)
+	if input.Repair {
+		for i, group := range check.Groups {
+			if group.Ok {
+				continue
+			}
+			_, err = controller.client.AddAssociations(associationSource(nodeId, group.Endpoint), group.GroupId, []model.AssociationAddress{{NodeId: controllerNodeId}})
+			if err != nil {
+				return check, fmt.Errorf("unable to repair lifeline group %v: %w", group.GroupId, err)
+			}
+			check.Groups[i].Repaired = true
+		}
+	}
+	return check, nil
+}
+
+// commands to endpoint devices (<prefix>:<nodeId>.<endpoint>) use their endpoint unless the input names one
+func getSourceEndpoint(controller *Controller, deviceId string, input AssociationInput) int64 {
+	if input.Endpoint != 0 {
+		return input.Endpoint
+	}
+	return controller.deviceIdToEndpoint(deviceId)
+}
+
+func associationSource(nodeId int64, endpoint int64) model.AssociationAddress {
+	source := model.AssociationAddress{NodeId: nodeId}
+	if endpoint > 0 {
+		source.Endpoint = &endpoint
+	}
+	return source
+}
+
+// merges the group infos of the node with the current members; groups unknown to the node info are added without label
+func getAssociationGroups(groups []model.AssociationGroup, associations []model.Association) (result []AssociationGroupInfo) {
+	result = []AssociationGroupInfo{}
+	index := map[[2]int64]int{}
+	for _, group := range groups {
+		index[[2]int64{group.Endpoint, group.GroupId}] = len(result)
+		result = append(result, AssociationGroupInfo{AssociationGroup: group, Members: []model.AssociationAddress{}})
+	}
+	for _, association := range associations {
+		key := [2]int64{association.Endpoint, association.GroupId}
+		i, ok := index[key]
+		if !ok {
+			i = len(result)
+			index[key] = i
+			result = append(result, AssociationGroupInfo{
+				AssociationGroup: model.AssociationGroup{GroupId: association.GroupId, Endpoint: association.Endpoint},
+				Members:          []model.AssociationAddress{},
+			})
+		}
+		result[i].Members = append(result[i].Members, model.AssociationAddress{NodeId: association.NodeId, Endpoint: association.TargetEndpoint})
+	}
+	slices.SortFunc(result, func(a, b AssociationGroupInfo) int {
+		if a.Endpoint != b.Endpoint {
+			return int(a.Endpoint - b.Endpoint)
+		}
+		return int(a.GroupId - b.GroupId)
+	})
+	return result
+}
+
+// if no group is marked as lifeline, group 1 of the root endpoint is used (z-wave plus convention)
+func checkLifeline(groups []AssociationGroupInfo, controllerNodeId int64) (result LifelineCheckResult) {
+	lifelines := []AssociationGroupInfo{}
+	for _, group := range groups {
+		if group.IsLifeline {
+			lifelines = append(lifelines, group)
+		}
+	}
+	if len(lifelines) == 0 {
+		lifelines = append(lifelines, AssociationGroupInfo{AssociationGroup: model.AssociationGroup{GroupId: 1}})
+		for _, group := range groups {
+			if group.GroupId == 1 && group.Endpoint == 0 {
+				lifelines[0] = group
+			}
+		}
+	}
+	result = LifelineCheckResult{Ok: true, Groups: []LifelineGroupResult{}}
+	for _, group := range lifelines {
+		ok := slices.ContainsFunc(group.Members, func(member model.AssociationAddress) bool {
+			return member.NodeId == controllerNodeId
+		})
+		result.Ok = result.Ok && ok
+		result.Groups = append(result.Groups, LifelineGroupResult{GroupId: group.GroupId, Endpoint: group.Endpoint, Ok: ok})
+	}
+	return result
+}
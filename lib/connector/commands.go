@@ -25,7 +25,15 @@ import (
 
 // expects ids from mgw (with prefixes and suffixes)
 func (this *Connector) CommandHandler(deviceId string, serviceId string, command mgw.Command) {
-	if this.isGetServiceId(serviceId) {
+	controller, ok := this.getControllerByDeviceId(deviceId)
+	handler, isDeviceService := this.getDeviceServiceHandler(serviceId)
+	if ok && controller.isControllerDeviceId(deviceId) {
+		this.handleControllerCommand(controller, deviceId, serviceId, command)
+	} else if ok && controller.isGroupDeviceId(deviceId) {
+		this.handleGroupCommand(controller, deviceId, serviceId, command)
+	} else if ok && isDeviceService {
+		this.handleDeviceServiceCommand(controller, deviceId, serviceId, command, handler)
+	} else if this.isGetServiceId(serviceId) {
 		this.handleGetCommand(deviceId, serviceId, command)
 	} else {
 		this.handleSetCommand(deviceId, serviceId, command)
@@ -58,7 +66,13 @@ func (this *Connector) handleGetCommand(deviceId string, serviceId string, comma
 
 // expects ids from mgw (with prefixes and suffixes)
 func (this *Connector) handleSetCommand(deviceId string, serviceId string, command mgw.Command) {
-	valueId := this.removeDeviceIdPrefix(deviceId) + "-" + model.DecodeLocalId(serviceId)
+	controller, ok := this.getControllerByDeviceId(deviceId)
+	if !ok {
+		this.config.GetLogger().Error("no controller found for device", "device", deviceId, "service", serviceId)
+		this.mgwClient.SendCommandError(command.CommandId, "no controller found for device "+deviceId)
+		return
+	}
+	valueId := controller.deviceIdToRawNodeId(deviceId) + "-" + model.DecodeLocalId(this.migrateCommandServiceId(controller, serviceId))
 	var value interface{}
 	err := json.Unmarshal([]byte(command.Data), &value)
 	if err != nil {
@@ -66,7 +80,7 @@ func (this *Connector) handleSetCommand(deviceId string, serviceId string, comma
 		this.mgwClient.SendCommandError(command.CommandId, "unable to Unmarshal command data to z2m value: "+err.Error())
 		return
 	}
-	err = this.z2mClient.SetValueByValueId(valueId, value)
+	err = controller.client.SetValueByValueId(valueId, value)
 	if err != nil {
 		this.config.GetLogger().Error("unable to send value to z2m", "device", deviceId, "service", serviceId, "value", value, "error", err)
 		this.mgwClient.SendCommandError(command.CommandId, "unable to send value to z2m: "+err.Error())
@@ -80,3 +94,20 @@ func (this *Connector) handleSetCommand(deviceId string, serviceId string, comma
 		return
 	}
 }
+
+// responds with the json encoded result; expects ids from mgw (with prefixes and suffixes)
+func (this *Connector) respondWithResult(deviceId string, serviceId string, command mgw.Command, result interface{}) {
+	temp, err := json.Marshal(result)
+	if err != nil {
+		this.config.GetLogger().Error("unable to marshal result to send as response", "device", deviceId, "service", serviceId, "error", err)
+		this.mgwClient.SendCommandError(command.CommandId, "unable to marshal result to send as response: "+err.Error())
+		return
+	}
+	command.Data = string(temp)
+	err = this.mgwClient.Respond(deviceId, serviceId, command)
+	if err != nil {
+		this.config.GetLogger().Error("unable to send response to mgw", "device", deviceId, "service", serviceId, "error", err)
+		this.mgwClient.SendCommandError(command.CommandId, "unable to send response to mgw: "+err.Error())
+		return
+	}
+}
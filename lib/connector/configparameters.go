@@ -0,0 +1,210 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+const ConfigurationCommandClass int64 = 112
+
+const ControllerServiceApplyConfigProfiles = "applyConfigProfiles"
+
+type ConfigParameter struct {
+	Parameter   int64       `json:"parameter"`
+	BitMask     *int64      `json:"bitMask,omitempty"`
+	Label       string      `json:"label"`
+	Description string      `json:"description"`
+	Min         interface{} `json:"min"`
+	Max         interface{} `json:"max"`
+	Default     interface{} `json:"default"`
+	Unit        string      `json:"unit"`
+	States      interface{} `json:"states,omitempty"`
+	Value       interface{} `json:"value"`
+	ReadOnly    bool        `json:"readOnly"`
+	LastUpdate  int64       `json:"lastUpdate"`
+}
+
+type ConfigParameterInput struct {
+	Parameter int64       `json:"parameter"`
+	BitMask   *int64      `json:"bitMask,omitempty"`
+	Value     interface{} `json:"value"`
+	Profile   string      `json:"profile"`
+}
+
+type ConfigParameterResult struct {
+	Parameter int64       `json:"parameter"`
+	BitMask   *int64      `json:"bitMask,omitempty"`
+	Value     interface{} `json:"value"`
+	Result    interface{} `json:"result"`
+}
+
+func getConfigParameters(node model.DeviceInfo) (result []ConfigParameter) {
+	result = []ConfigParameter{}
+	for _, value := range node.Values {
+		if value.ClassId != ConfigurationCommandClass {
+			continue
+		}
+		parameter, ok := toInt64(value.Property)
+		if !ok {
+			//zwave2mqtt uses the index as parameter number
+			parameter = value.Index
+		}
+		var bitMask *int64
+		if key, ok := toInt64(value.PropertyKey); ok {
+			bitMask = &key
+		}
+		result = append(result, ConfigParameter{
+			Parameter:   parameter,
+			BitMask:     bitMask,
+			Label:       value.Label,
+			Description: value.Description,
+			Min:         value.Min,
+			Max:         value.Max,
+			Default:     value.Default,
+			Unit:        value.Unit,
+			States:      value.States,
+			Value:       value.Value,
+			ReadOnly:    value.ReadOnly,
+			LastUpdate:  value.LastUpdate,
+		})
+	}
+	slices.SortFunc(result, func(a, b ConfigParameter) int {
+		if a.Parameter != b.Parameter {
+			return int(a.Parameter - b.Parameter)
+		}
+		if a.BitMask == nil || b.BitMask == nil {
+			return 0
+		}
+		return int(*a.BitMask - *b.BitMask)
+	})
+	return result
+}
+
+func toInt64(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	case string:
+		i, err := strconv.ParseInt(v, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func parseConfigParameterInput(data string) (input ConfigParameterInput, err error) {
+	if data == "" {
+		return input, nil
+	}
+	err = json.Unmarshal([]byte(data), &input)
+	if err != nil {
+		return input, fmt.Errorf("unable to unmarshal command data: %w", err)
+	}
+	return input, nil
+}
+
+func (this *Connector) listConfigParametersCommand(controller *Controller, deviceId string, _ int64, _ string) (result interface{}, err error) {
+	node, ok := this.nodeStoreGet(controller, deviceId)
+	if !ok {
+		return nil, fmt.Errorf("no node info known for %v", deviceId)
+	}
+	return getConfigParameters(node), nil
+}
+
+func (this *Connector) getConfigParameterCommand(controller *Controller, _ string, nodeId int64, data string) (result interface{}, err error) {
+	input, err := parseConfigParameterInput(data)
+	if err != nil {
+		return nil, err
+	}
+	return controller.client.GetConfigParameter(nodeId, input.Parameter, input.BitMask)
+}
+
+func (this *Connector) setConfigParameterCommand(controller *Controller, _ string, nodeId int64, data string) (result interface{}, err error) {
+	input, err := parseConfigParameterInput(data)
+	if err != nil {
+		return nil, err
+	}
+	return controller.client.SetConfigParameter(nodeId, input.Parameter, input.BitMask, input.Value)
+}
+
+// applies the profile named in the command data or, if no profile is named, the profile of the nodes type mapping key
+func (this *Connector) applyConfigProfileCommand(controller *Controller, deviceId string, _ int64, data string) (result interface{}, err error) {
+	input, err := parseConfigParameterInput(data)
+	if err != nil {
+		return nil, err
+	}
+	node, ok := this.nodeStoreGet(controller, deviceId)
+	if !ok {
+		return nil, fmt.Errorf("no node info known for %v", deviceId)
+	}
+	profileKey := input.Profile
+	if profileKey == "" {
+		profileKey = node.GetTypeMappingKey()
+	}
+	return this.applyConfigProfile(controller, node.NodeId, profileKey)
+}
+
+func (this *Connector) applyConfigProfile(controller *Controller, nodeId int64, profileKey string) (result []ConfigParameterResult, err error) {
+	profile, ok := this.config.ConfigParameterProfiles[model.CanonicalTypeMappingKey(profileKey)]
+	if !ok {
+		return nil, fmt.Errorf("no config parameter profile found for %v", profileKey)
+	}
+	result = []ConfigParameterResult{}
+	for _, setting := range profile {
+		setResult, setErr := controller.client.SetConfigParameter(nodeId, setting.Parameter, setting.BitMask, setting.Value)
+		if setErr != nil {
+			err = errors.Join(err, fmt.Errorf("parameter %v: %w", setting.Parameter, setErr))
+			continue
+		}
+		result = append(result, ConfigParameterResult{
+			Parameter: setting.Parameter,
+			BitMask:   setting.BitMask,
+			Value:     setting.Value,
+			Result:    setResult,
+		})
+	}
+	return result, err
+}
+
+// applies the matching profiles to all known nodes of the controller; returns the results or errors per device id
+func (this *Connector) applyConfigProfiles(controller *Controller) (result map[string]interface{}, err error) {
+	result = map[string]interface{}{}
+	for _, node := range this.nodeStoreGetAll(controller) {
+		if _, ok := this.config.ConfigParameterProfiles[node.GetCanonicalTypeMappingKey()]; !ok {
+			continue
+		}
+		deviceId := controller.nodeIdToDeviceId(node.NodeId)
+		nodeResult, nodeErr := this.applyConfigProfile(controller, node.NodeId, node.GetCanonicalTypeMappingKey())
+		if nodeErr != nil {
+			this.config.GetLogger().Warn("unable to apply config parameter profile", "device", deviceId, "error", nodeErr)
+			result[deviceId] = nodeErr.Error()
+		} else {
+			result[deviceId] = nodeResult
+		}
+	}
+	return result, nil
+}
@@ -19,19 +19,17 @@ package connector
 import (
 	"context"
 	"encoding/json"
-	"errors"
-	"strconv"
 	"strings"
 	"sync"
+	"text/template"
 	"time"
 
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo"
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo/auth"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo/fallback"
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/mgw"
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
-	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/zwave2mqtt"
-	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/zwavejs2mqtt"
 	"github.com/SENERGY-Platform/models/go/models"
 )
 
@@ -40,26 +38,84 @@ type Z2mClient interface {
 	SetValueEventListener(listener func(nodeValue model.Value))
 	SetDeviceInfoListener(listener func(nodes []model.DeviceInfo, huskIds []int64, withValues bool, allKnownDevices bool))
 	RequestDeviceInfoUpdate() error
+	GetControllerNodeId() int64
 	SetValueByValueId(id string, value interface{}) error
+	PollValue(valueId string, timeout time.Duration) (interface{}, error)
+	SetNodeName(nodeId int64, name string) (interface{}, error)
+	SetNodeLocation(nodeId int64, location string) (interface{}, error)
+	WriteMulticast(nodeIds []int64, localValueId string, value interface{}) error
+	WriteBroadcast(localValueId string, value interface{}) error
 	SetDeviceStatusListener(state func(nodeId int64, online bool) error)
+	SetControllerEventListener(listener func(event string, data []interface{}))
+	SetNodeEventListener(listener func(event string, nodeId int64, data []interface{}))
+
+	StartInclusion(strategy int64, options map[string]interface{}) (interface{}, error)
+	StopInclusion() (interface{}, error)
+	StartExclusion() (interface{}, error)
+	StopExclusion() (interface{}, error)
+	HealNetwork() (interface{}, error)
+	HealNode(nodeId int64) (interface{}, error)
+	RemoveFailedNode(nodeId int64) (interface{}, error)
+	ReplaceFailedNode(nodeId int64, strategy int64) (interface{}, error)
+	SoftReset() (interface{}, error)
+	RefreshNeighbors() (interface{}, error)
+
+	GetConfigParameter(nodeId int64, parameter int64, bitMask *int64) (interface{}, error)
+	SetConfigParameter(nodeId int64, parameter int64, bitMask *int64, value interface{}) (interface{}, error)
+
+	BeginFirmwareUpdate(nodeId int64, fileName string, data []byte, target int64) (interface{}, error)
+	AbortFirmwareUpdate(nodeId int64) (interface{}, error)
+	RefreshInfo(nodeId int64) (interface{}, error)
+
+	PingNode(nodeId int64) (interface{}, error)
+	RefreshValues(nodeId int64) (interface{}, error)
+	CheckLifelineHealth(nodeId int64, rounds int64) (interface{}, error)
+
+	GetAssociations(nodeId int64) ([]model.Association, error)
+	AddAssociations(source model.AssociationAddress, groupId int64, targets []model.AssociationAddress) (interface{}, error)
+	RemoveAssociations(source model.AssociationAddress, groupId int64, targets []model.AssociationAddress) (interface{}, error)
 }
 
 type DeviceRepo interface {
 	FindDeviceTypeId(device model.DeviceInfo) (dtId string, usedFallback bool, err error)
 	CreateDeviceTypeWithDistinctAttributes(key string, dt models.DeviceType, attributeKeys []string) (result models.DeviceType, code int, err error)
+	GetDeviceType(id string) (result models.DeviceType, err error)
+	UpdateDeviceType(dt models.DeviceType, attributeKeys []string) (result models.DeviceType, code int, err error)
+	CircuitState() devicerepo.CircuitState
+	GetSemanticIds() (map[string]string, error)
 }
 
 type Connector struct {
 	config                       configuration.Config
 	mgwClient                    *mgw.Client
-	z2mClient                    Z2mClient
+	controllers                  []*Controller
 	deviceRegister               map[string]mgw.DeviceInfo
 	deviceRegisterMux            sync.Mutex
 	valueStore                   map[string]interface{}
 	valueStoreMux                sync.Mutex
+	nodeStore                    map[string]model.DeviceInfo
+	nodeStoreMux                 sync.Mutex
+	deviceGroups                 map[string]configuration.DeviceGroupConfig //by group device id
+	deviceGroupsMux              sync.Mutex
+	storedDeviceGroupsMux        sync.Mutex
+	polling                      pollScheduler
+	nameTemplate                 *template.Template
+	deviceNames                  map[string]storedDeviceName //names set with the rename service, by device id; loaded from the fallback file
+	pushedNodeNames              map[string]string           //by device id; name_sync pushes each name once
+	deviceNamesMux               sync.Mutex
+	unresolvedSemanticNames      sync.Map //names of semantic rules without id and load errors; logged once
+	serviceIdMigrator            *serviceIdMigrator
+	deviceTypeRules              []deviceTypeRule
+	reportedDeviceTypeGaps       map[string]string //device type id --> reported missing services
+	reportedDeviceTypeGapsMux    sync.Mutex
+	deviceTypeSyncs              map[string]bool //by device type id and value services; running or completed syncs
+	deviceTypeSyncsMux           sync.Mutex
+	deferredNodes                map[*Controller]map[int64]bool //nodes waiting for a device type list refresh
+	deferredNodesMux             sync.Mutex
 	connectorId                  string
-	deviceIdPrefix               string
 	deviceTypeMapping            map[string]string
+	typeMappingMux               sync.Mutex //guards deviceTypeMapping and nodeDeviceTypeOverwrite
+	typeMappingFileContent       []byte
 	updateTicker                 *time.Ticker
 	updateTickerDuration         time.Duration
 	deleteMissingDevices         bool
@@ -67,6 +123,7 @@ type Connector struct {
 	eventsForUnregisteredDevices bool
 	nodeDeviceTypeOverwrite      map[string]string
 	devicerepo                   DeviceRepo
+	store                        fallback.Fallback //persistent connector state in the fallback file; nil without device repository
 }
 
 func New(config configuration.Config, ctx context.Context) (result *Connector, err error) {
@@ -74,30 +131,53 @@ func New(config configuration.Config, ctx context.Context) (result *Connector, e
 		config:                       config,
 		deviceRegister:               map[string]mgw.DeviceInfo{},
 		valueStore:                   map[string]interface{}{},
+		nodeStore:                    map[string]model.DeviceInfo{},
+		deviceGroups:                 map[string]configuration.DeviceGroupConfig{},
 		connectorId:                  config.ConnectorId,
-		deviceIdPrefix:               config.DeviceIdPrefix,
-		deviceTypeMapping:            config.DeviceTypeMapping,
+		deviceTypeMapping:            canonicalizeMappingKeys(config.DeviceTypeMapping, config.GetLogger()),
 		deleteMissingDevices:         config.DeleteMissingDevices,
 		husksShouldBeDeleted:         config.DeleteHusks,
 		eventsForUnregisteredDevices: config.EventsForUnregisteredDevices,
 		nodeDeviceTypeOverwrite:      config.NodeDeviceTypeOverwrite,
+		serviceIdMigrator:            newServiceIdMigrator(config.ServiceIdMigrations),
+		reportedDeviceTypeGaps:       map[string]string{},
+		deviceTypeSyncs:              map[string]bool{},
 	}
 
-	result.devicerepo, err = devicerepo.New(config, &auth.Auth{})
+	result.config.ConfigParameterProfiles = canonicalizeMappingKeys(config.ConfigParameterProfiles, config.GetLogger())
+
+	result.nameTemplate, err = parseNameTemplate(config.DeviceNameTemplate)
 	if err != nil {
 		return nil, err
 	}
 
-	switch config.ZwaveController {
-	case "":
-		fallthrough
-	case "zwave2mqtt":
-		result.z2mClient, err = zwave2mqtt.New(config, ctx)
-	case "zwavejs2mqtt":
-		result.z2mClient, err = zwavejs2mqtt.New(config, ctx)
-	default:
-		err = errors.New("unknown zwave controller")
+	result.deviceTypeRules, err = parseDeviceTypeRules(config.DeviceTypeRules)
+	if err != nil {
+		return nil, err
 	}
+
+	if config.DeviceTypeMappingFile != "" {
+		_, err = result.loadTypeMappingFile()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	repo, err := devicerepo.New(config, &auth.Auth{})
+	if err != nil {
+		return nil, err
+	}
+	repo.SetRefreshListener(result.registerDeferredDevices)
+	repo.SetCircuitStateListener(result.deviceRepositoryStateChanged)
+	result.devicerepo = repo
+	result.store = repo.GetFallback()
+
+	err = result.initControllers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = result.initDeviceGroups()
 	if err != nil {
 		return nil, err
 	}
@@ -106,10 +186,8 @@ func New(config configuration.Config, ctx context.Context) (result *Connector, e
 	if err != nil {
 		return nil, err
 	}
-	result.z2mClient.SetErrorForwardingFunc(result.mgwClient.SendClientError)
-	result.z2mClient.SetValueEventListener(result.ValueEventListener)
-	result.z2mClient.SetDeviceInfoListener(result.DeviceInfoListener)
-	result.z2mClient.SetDeviceStatusListener(result.SetDeviceState)
+	repo.StartBackgroundRefresh(ctx)
+	result.connectControllers()
 
 	if config.UpdatePeriod != "" && config.UpdatePeriod != "-" {
 		result.updateTickerDuration, err = time.ParseDuration(config.UpdatePeriod)
@@ -130,13 +208,17 @@ func New(config configuration.Config, ctx context.Context) (result *Connector, e
 				case <-ctx.Done():
 					return
 				case <-result.updateTicker.C:
-					config.GetLogger().Info("send periodical update request to z2m", "result", result.z2mClient.RequestDeviceInfoUpdate())
+					config.GetLogger().Info("send periodical update request to z2m", "result", result.requestDeviceInfoUpdates())
 				}
 			}
 		}()
 	}
 
-	config.GetLogger().Info(" update request", "result", result.z2mClient.RequestDeviceInfoUpdate())
+	result.startTopologyRefresh(ctx)
+	result.startPolling(ctx)
+	result.watchTypeMappingFile(ctx)
+
+	config.GetLogger().Info(" update request", "result", result.requestDeviceInfoUpdates())
 
 	go func() {
 		timer := time.NewTimer(config.InitialUpdateRequestDelay.GetDuration())
@@ -152,7 +234,7 @@ func New(config configuration.Config, ctx context.Context) (result *Connector, e
 		case <-ctx.Done():
 			return
 		case <-timer.C:
-			config.GetLogger().Info("delayed initial update request", "result", result.z2mClient.RequestDeviceInfoUpdate())
+			config.GetLogger().Info("delayed initial update request", "result", result.requestDeviceInfoUpdates())
 		}
 	}()
 
@@ -160,10 +242,9 @@ func New(config configuration.Config, ctx context.Context) (result *Connector, e
 }
 
 // returns ids for mgw (with prefixes and suffixes) and the value
-func (this *Connector) parseNodeValueAsMgwEvent(nodeValue model.Value) (deviceId string, serviceId string, value interface{}, err error) {
+func (this *Connector) parseNodeValueAsMgwEvent(controller *Controller, nodeValue model.Value) (deviceId string, serviceId string, value interface{}, err error) {
 	serviceId = nodeValue.GetServiceId(true)
-	rawDeviceId := strconv.FormatInt(nodeValue.NodeId, 10)
-	deviceId = this.addDeviceIdPrefix(rawDeviceId)
+	deviceId = this.getDeviceIdForValue(controller, nodeValue)
 	value = ValueWithTimestamp{
 		Value:      nodeValue.Value,
 		LastUpdate: nodeValue.LastUpdate,
@@ -180,18 +261,6 @@ func (this *Connector) isGetServiceId(serviceId string) bool {
 	return strings.HasSuffix(serviceId, ":get")
 }
 
-func (this *Connector) nodeIdToDeviceId(nodeId int64) string {
-	return this.addDeviceIdPrefix(strconv.FormatInt(nodeId, 10))
-}
-
-func (this *Connector) addDeviceIdPrefix(rawDeviceId string) string {
-	return this.deviceIdPrefix + ":" + rawDeviceId
-}
-
-func (this *Connector) removeDeviceIdPrefix(deviceId string) string {
-	return strings.Replace(deviceId, this.deviceIdPrefix+":", "", 1)
-}
-
 type Duration struct {
 	dur time.Duration
 }
@@ -17,8 +17,24 @@
 package connector
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo/fallback"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/mgw"
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/zwavejs2mqtt"
+	"github.com/SENERGY-Platform/models/go/models"
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func Test_encodeLocalId(t *testing.T) {
@@ -51,3 +67,1250 @@ func FuzzLocalIdEncode(f *testing.F) {
 		}
 	})
 }
+
+func TestGetControllerByDeviceId(t *testing.T) {
+	a := &Controller{deviceIdPrefix: "site"}
+	b := &Controller{deviceIdPrefix: "site:b"}
+	c := &Connector{controllers: []*Controller{a, b}}
+	if result, ok := c.getControllerByDeviceId("site:5"); !ok || result != a {
+		t.Error(result, ok)
+	}
+	if result, ok := c.getControllerByDeviceId("site:b:5"); !ok || result != b {
+		t.Error(result, ok)
+	}
+	if result, ok := c.getControllerByDeviceId("other:5"); ok {
+		t.Error(result, ok)
+	}
+	if raw := b.removeDeviceIdPrefix("site:b:5"); raw != "5" {
+		t.Error(raw)
+	}
+	missing := configuration.ControllerConfig{ZwaveController: "zwavejs2mqtt", ZwaveMqttApiTopic: "zwave/api"}.MissingTopics()
+	if !slices.Equal(missing, []string{"zwave_mqtt_device_state_topic"}) {
+		t.Error(missing)
+	}
+}
+
+func TestSplitNodeByEndpoints(t *testing.T) {
+	node := model.DeviceInfo{
+		NodeId:         5,
+		Name:           "strip",
+		ManufacturerId: "271",
+		ProductType:    "6913",
+		ProductId:      "4096",
+		Values: map[string]model.Value{
+			"5-37-0-currentValue": {NodeId: 5, Instance: 0},
+			"5-37-1-currentValue": {NodeId: 5, Instance: 1},
+			"5-37-2-currentValue": {NodeId: 5, Instance: 2},
+		},
+	}
+	c := &Connector{}
+	if result, err := c.splitNodeByEndpoints(node); err != nil || len(result) != 1 || len(result[0].Values) != 3 {
+		t.Error(result, err)
+	}
+	c.config.SplitEndpoints = []string{"271.6913.4096"}
+	result, err := c.splitNodeByEndpoints(node)
+	if err != nil || len(result) != 3 {
+		t.Fatal(result, err)
+	}
+	expectedIds := []string{"5", "5.1", "5.2"}
+	expectedKeys := []string{"271.6913.4096", "271.6913.4096.1", "271.6913.4096.2"}
+	for i, device := range result {
+		if device.GetRawDeviceId() != expectedIds[i] || device.GetTypeMappingKey() != expectedKeys[i] || len(device.Values) != 1 {
+			t.Error(i, device.GetRawDeviceId(), device.GetTypeMappingKey(), device.Values)
+		}
+	}
+	if result[1].Name != "strip 1" {
+		t.Error(result[1].Name)
+	}
+
+	repo := &splitTestRepo{err: fmt.Errorf("%w: test", devicerepo.ErrDeviceTypeListPending)}
+	c = &Connector{devicerepo: repo, config: configuration.Config{SplitEndpoints: []string{"urn:infai:ses:device-type:strip"}, CreateMissingDeviceTypes: true}}
+	if _, err = c.splitNodeByEndpoints(node); !errors.Is(err, devicerepo.ErrDeviceTypeListPending) {
+		t.Error("expected pending split decision", err)
+	}
+	repo.err = fmt.Errorf("%w: test", model.NoMatchingDeviceTypeFound)
+	if result, err = c.splitNodeByEndpoints(node); err != nil || len(result) != 1 || repo.created != 0 {
+		t.Error("expected no split and no created device type", result, err, repo.created)
+	}
+	repo.err = nil
+	if result, err = c.splitNodeByEndpoints(node); err != nil || len(result) != 3 {
+		t.Error("expected split by device type", result, err)
+	}
+
+	controller := &Controller{deviceIdPrefix: "prefix"}
+	if nodeId := controller.deviceIdToRawNodeId("prefix:5.2"); nodeId != "5" {
+		t.Error(nodeId)
+	}
+}
+
+type splitTestRepo struct {
+	DeviceRepo
+	err     error
+	created int
+}
+
+func (this *splitTestRepo) FindDeviceTypeId(model.DeviceInfo) (string, bool, error) {
+	return "urn:infai:ses:device-type:strip", false, this.err
+}
+
+func (this *splitTestRepo) CreateDeviceTypeWithDistinctAttributes(string, models.DeviceType, []string) (models.DeviceType, int, error) {
+	this.created++
+	return models.DeviceType{Id: "created"}, 200, nil
+}
+
+func TestBuildTopology(t *testing.T) {
+	rssi := -60.0
+	c := &Connector{}
+	controller := &Controller{deviceIdPrefix: "prefix"}
+	topology := c.buildTopology(controller, []model.DeviceInfo{
+		{NodeId: 2, Topology: model.Topology{Neighbors: []int64{1, 3}, IsRouting: true}},
+		{NodeId: 3, Topology: model.Topology{Neighbors: []int64{2}, LastWorkingRoute: &model.Route{Repeaters: []int64{2}, Rssi: &rssi, RepeaterRssi: []float64{-80}}}},
+	})
+	if len(topology.Nodes) != 3 || !topology.Nodes[0].IsController || topology.Nodes[0].DeviceId != "prefix:controller" || topology.Nodes[2].DeviceId != "prefix:3" {
+		t.Error(topology.Nodes)
+	}
+	neighbors := 0
+	routes := []TopologyEdge{}
+	for _, edge := range topology.Edges {
+		switch edge.Type {
+		case EdgeTypeNeighbor:
+			neighbors++
+		case EdgeTypeRoute:
+			routes = append(routes, edge)
+		}
+	}
+	if neighbors != 2 {
+		t.Error(topology.Edges)
+	}
+	if len(routes) != 2 || routes[0].Source != 3 || routes[0].Target != 2 || *routes[0].Rssi != -80 || routes[1].Target != 1 || *routes[1].Rssi != -60 {
+		t.Error(routes)
+	}
+}
+
+type controllerNodeTestClient struct {
+	Z2mClient
+	controllerNodeId int64
+}
+
+func (this *controllerNodeTestClient) GetControllerNodeId() int64 {
+	return this.controllerNodeId
+}
+
+func TestBuildTopologyControllerNodeId(t *testing.T) {
+	c := &Connector{}
+	controller := &Controller{deviceIdPrefix: "prefix", client: &controllerNodeTestClient{controllerNodeId: 5}}
+	topology := c.buildTopology(controller, []model.DeviceInfo{
+		{NodeId: 1, Topology: model.Topology{Neighbors: []int64{5}, LastWorkingRoute: &model.Route{}}},
+	})
+	if len(topology.Nodes) != 2 || topology.Nodes[0].NodeId != 1 || topology.Nodes[0].IsController || topology.Nodes[1].NodeId != 5 || !topology.Nodes[1].IsController {
+		t.Error(topology.Nodes)
+	}
+	for _, edge := range topology.Edges {
+		if edge.Type == EdgeTypeRoute && (edge.Source != 1 || edge.Target != 5) {
+			t.Error(edge)
+		}
+	}
+}
+
+func TestParseGetNodesResultControllerNodeId(t *testing.T) {
+	payload := []byte(`{"success":true,"result":[{"id":3,"isControllerNode":true},{"id":1,"isControllerNode":false,"manufacturer":"m","manufacturerId":1,"productType":2,"productId":3,"productDescription":"p"}]}`)
+	_, _, controllerNodeId, err := zwavejs2mqtt.ParseGetNodesResult(payload)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if controllerNodeId != 3 {
+		t.Error(controllerNodeId)
+	}
+}
+
+func TestFirmwareUpdateReservation(t *testing.T) {
+	controller := &Controller{deviceIdPrefix: "site", pendingInterviews: map[int64]bool{}}
+	c := &Connector{controllers: []*Controller{controller}}
+	if err := c.reserveFirmwareUpdate(controller, 5, FirmwareUpdateInput{File: "fw.bin"}); err != nil {
+		t.Error(err)
+	}
+	if err := c.reserveFirmwareUpdate(controller, 6, FirmwareUpdateInput{File: "fw.bin"}); err == nil {
+		t.Error("expected error for concurrent update")
+	}
+	if _, ok := c.releaseFirmwareUpdate(controller, 6); ok {
+		t.Error("released update of other node")
+	}
+	if update, ok := c.releaseFirmwareUpdate(controller, 5); !ok || update.file != "fw.bin" {
+		t.Error(update, ok)
+	}
+	if err := c.reserveFirmwareUpdate(controller, 6, FirmwareUpdateInput{File: "fw.bin"}); err != nil {
+		t.Error(err)
+	}
+	c.releaseFirmwareUpdate(controller, 6)
+}
+
+func TestLoadFirmwareFile(t *testing.T) {
+	dir := t.TempDir()
+	firmwareDir := filepath.Join(dir, "firmware")
+	if err := os.Mkdir(firmwareDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(firmwareDir, "fw.otz"), []byte("firmware"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret"), []byte("secret"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := &Connector{config: configuration.Config{FirmwareDirectory: firmwareDir}}
+	if name, content, err := c.loadFirmwareFile("fw.otz"); err != nil || name != "fw.otz" || string(content) != "firmware" {
+		t.Error(name, string(content), err)
+	}
+	if _, _, err := c.loadFirmwareFile(filepath.Join(firmwareDir, "fw.otz")); err != nil {
+		t.Error(err)
+	}
+	for _, file := range []string{"../secret", filepath.Join(dir, "secret")} {
+		if _, _, err := c.loadFirmwareFile(file); err == nil {
+			t.Error("expected error for file outside of firmware_directory", file)
+		}
+	}
+	c.config.FirmwareMaxSize = 4
+	if _, _, err := c.loadFirmwareFile("fw.otz"); err == nil {
+		t.Error("expected error for oversized firmware")
+	}
+	c.config.FirmwareDirectory = ""
+	if _, _, err := c.loadFirmwareFile("fw.otz"); err == nil {
+		t.Error("expected error without firmware_directory")
+	}
+}
+
+func TestNextHuskAction(t *testing.T) {
+	now := time.Now()
+	state := huskRecoveryState{}
+	expected := []struct {
+		offset time.Duration
+		action huskAction
+	}{
+		{0, huskReInterview},
+		{time.Minute, huskWait},
+		{10 * time.Minute, huskReInterview},
+		{25 * time.Minute, huskWait},
+		{30 * time.Minute, huskGiveUp},
+	}
+	for i, e := range expected {
+		var action huskAction
+		state, action = nextHuskAction(state, now.Add(e.offset), 2, 10*time.Minute)
+		if action != e.action {
+			t.Error(i, action, e.action, state)
+		}
+	}
+	state.reported = true
+	if next, action := nextHuskAction(state, now.Add(time.Hour), 2, 10*time.Minute); action != huskGiveUp || !next.reported {
+		t.Error("expected given up husk to stay reported", action, next)
+	}
+}
+
+func TestAssociationGroups(t *testing.T) {
+	endpoint := int64(2)
+	groups := []model.AssociationGroup{
+		{GroupId: 2, Label: "On/Off"},
+		{GroupId: 1, Label: "Lifeline", IsLifeline: true},
+	}
+	associations := []model.Association{
+		{GroupId: 2, NodeId: 7, TargetEndpoint: &endpoint},
+		{GroupId: 1, Endpoint: 1, NodeId: 1},
+	}
+	result := getAssociationGroups(groups, associations)
+	if len(result) != 3 || result[0].GroupId != 1 || result[1].GroupId != 2 || result[2].Endpoint != 1 {
+		t.Fatal(result)
+	}
+	if len(result[1].Members) != 1 || result[1].Members[0].NodeId != 7 || *result[1].Members[0].Endpoint != 2 {
+		t.Error(result[1])
+	}
+	check := checkLifeline(result, model.DefaultControllerNodeId)
+	if check.Ok || len(check.Groups) != 1 || check.Groups[0].Ok {
+		t.Error(check)
+	}
+	associations = append(associations, model.Association{GroupId: 1, NodeId: 1})
+	check = checkLifeline(getAssociationGroups(groups, associations), model.DefaultControllerNodeId)
+	if !check.Ok {
+		t.Error(check)
+	}
+	controller := &Controller{deviceIdPrefix: "site"}
+	if source := getSourceEndpoint(controller, "site:5.2", AssociationInput{}); source != 2 {
+		t.Error(source)
+	}
+	if source := getSourceEndpoint(controller, "site:5.2", AssociationInput{Endpoint: 1}); source != 1 {
+		t.Error(source)
+	}
+	if source := getSourceEndpoint(controller, "site:5", AssociationInput{}); source != 0 {
+		t.Error(source)
+	}
+}
+
+type groupTestClient struct {
+	Z2mClient
+	multicastErr error
+	multicasts   [][]int64
+	sets         []string
+}
+
+func (this *groupTestClient) WriteMulticast(nodeIds []int64, _ string, _ interface{}) error {
+	this.multicasts = append(this.multicasts, nodeIds)
+	return this.multicastErr
+}
+
+func (this *groupTestClient) SetValueByValueId(id string, _ interface{}) error {
+	this.sets = append(this.sets, id)
+	return nil
+}
+
+func TestStoredDeviceGroups(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "fallback.json")
+	newConnector := func() *Connector {
+		store, err := fallback.NewFallback(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &Connector{
+			controllers:  []*Controller{{deviceIdPrefix: "site"}},
+			deviceGroups: map[string]configuration.DeviceGroupConfig{},
+			store:        store,
+		}
+	}
+	c := newConnector()
+	c.updateStoredDeviceGroup(configuration.DeviceGroupConfig{Name: "a", DeviceIdPrefix: "site", NodeIds: []int64{2, 3}}, false)
+	c.updateStoredDeviceGroup(configuration.DeviceGroupConfig{Name: "b", DeviceIdPrefix: "site", NodeIds: []int64{4, 5}}, false)
+	c.updateStoredDeviceGroup(configuration.DeviceGroupConfig{Name: "b", DeviceIdPrefix: "site"}, true)
+
+	restarted := newConnector()
+	if err := restarted.initDeviceGroups(); err != nil {
+		t.Fatal(err)
+	}
+	group, ok := restarted.getDeviceGroup("site:group:a")
+	if !ok || !slices.Equal(group.NodeIds, []int64{2, 3}) || len(restarted.deviceGroups) != 1 {
+		t.Error(restarted.deviceGroups)
+	}
+	if _, err := restarted.createGroupCommand(restarted.controllers[0], configuration.DeviceGroupConfig{Name: "a", NodeIds: []int64{4}}); err == nil {
+		t.Error("expected error for duplicate group name")
+	}
+	if _, err := restarted.addDeviceGroup(configuration.DeviceGroupConfig{Name: "all", Broadcast: true}); err == nil {
+		t.Error("expected error for broadcast group without device_type_id")
+	}
+}
+
+func TestWriteGroupValue(t *testing.T) {
+	controller := &Controller{deviceIdPrefix: "site"}
+	c := &Connector{controllers: []*Controller{controller}, nodeStore: map[string]model.DeviceInfo{}}
+	for _, nodeId := range []int64{5, 6} {
+		c.nodeStoreSet(controller, model.DeviceInfo{NodeId: nodeId, Values: map[string]model.Value{
+			"v": {ComputedServiceId: "37-0-targetValue"},
+		}}, true)
+	}
+	group := configuration.DeviceGroupConfig{Name: "room", NodeIds: []int64{5, 6, 7}}
+
+	client := &groupTestClient{}
+	controller.client = client
+	if err := c.writeGroupValue(controller, group, "37-0-targetValue", true); err != nil {
+		t.Error(err)
+	}
+	if len(client.multicasts) != 1 || !slices.Equal(client.multicasts[0], []int64{5, 6}) || !slices.Equal(client.sets, []string{"7-37-0-targetValue"}) {
+		t.Error(client.multicasts, client.sets)
+	}
+
+	client = &groupTestClient{multicastErr: errors.New("test")}
+	controller.client = client
+	if err := c.writeGroupValue(controller, group, "37-0-targetValue", true); err != nil {
+		t.Error(err)
+	}
+	if !slices.Equal(client.sets, []string{"7-37-0-targetValue", "5-37-0-targetValue", "6-37-0-targetValue"}) {
+		t.Error(client.sets)
+	}
+}
+
+func TestPollTasks(t *testing.T) {
+	controller := &Controller{deviceIdPrefix: "site"}
+	c := &Connector{
+		controllers:    []*Controller{controller},
+		deviceRegister: map[string]mgw.DeviceInfo{},
+		config: configuration.Config{PollingRules: []configuration.PollingRule{
+			{MappingKey: "1.2.3", ServiceIds: []string{"50-0-value-65537"}},
+		}},
+	}
+	c.config.PollingRules[0].Interval.SetDuration(time.Minute)
+	nodes := []model.DeviceInfo{{NodeId: 5, ManufacturerId: "1", ProductType: "2", ProductId: "3", Values: map[string]model.Value{
+		"a": {ValueId: "5-50-0-value-65537", NodeId: 5, ComputedServiceId: "50-0-value-65537"},
+		"b": {ValueId: "5-37-0-currentValue", NodeId: 5, ComputedServiceId: "37-0-currentValue"},
+	}}}
+	c.updatePollTasks(controller, nodes)
+	if len(c.polling.tasks) != 1 || c.polling.tasks["site:5-50-0-value-65537"] == nil {
+		t.Fatal(c.polling.tasks)
+	}
+	next := c.polling.tasks["site:5-50-0-value-65537"].next
+	c.updatePollTasks(controller, nodes)
+	if c.polling.tasks["site:5-50-0-value-65537"].next != next {
+		t.Error("schedule of existing task changed")
+	}
+	if due := c.getDuePollTasks(next.Add(-time.Millisecond)); len(due) != 0 {
+		t.Error(due)
+	}
+	due := c.getDuePollTasks(next)
+	if len(due) != 1 || !c.polling.tasks["site:5-50-0-value-65537"].next.Equal(next.Add(time.Minute)) {
+		t.Fatal(due)
+	}
+	c.postponePollTask(due[0])
+	if due = c.getDuePollTasks(time.Now()); len(due) != 1 {
+		t.Error("expected postponed task to be due on the next tick", due)
+	}
+	c.updatePollTasks(controller, []model.DeviceInfo{})
+	if len(c.polling.tasks) != 0 {
+		t.Error(c.polling.tasks)
+	}
+
+	c.config.PollingRules[0].ServiceIds = nil
+	nodes[0].Values["c"] = model.Value{ValueId: "5-112-0-1", NodeId: 5, ClassId: 112, ComputedServiceId: "112-0-1"}
+	c.updatePollTasks(controller, nodes)
+	if len(c.polling.tasks) != 2 || c.polling.tasks["site:5-112-0-1"] != nil {
+		t.Error("values hidden by filters should not be polled", c.polling.tasks)
+	}
+
+	client := &pollTestClient{err: errors.New("timeout")}
+	controller.client = client
+	c.nodeStore = map[string]model.DeviceInfo{"site:5": {NodeId: 5, IsListening: true, Status: model.NodeStatusAlive}}
+	task := *c.polling.tasks["site:5-50-0-value-65537"]
+	c.poll(task)
+	if !c.polling.tasks["site:5-50-0-value-65537"].failing || c.setPollTaskFailing(task, true) {
+		t.Error("repeated failures should not change the failing state")
+	}
+	client.err = model.ErrNotSupported
+	c.poll(task)
+	c.updatePollTasks(controller, nodes)
+	if len(c.polling.tasks) != 0 || client.polls != 2 {
+		t.Error("poll tasks of controllers without pollValue should be removed", c.polling.tasks, client.polls)
+	}
+}
+
+type pollTestClient struct {
+	Z2mClient
+	err   error
+	polls int
+}
+
+func (this *pollTestClient) PollValue(string, time.Duration) (interface{}, error) {
+	this.polls++
+	return nil, this.err
+}
+
+func TestResolveDeviceName(t *testing.T) {
+	controller := &Controller{deviceIdPrefix: "site"}
+	c := &Connector{controllers: []*Controller{controller}, deviceRegister: map[string]mgw.DeviceInfo{}}
+	node := model.DeviceInfo{NodeId: 5, Product: "plug"}
+	if name := c.resolveDeviceName(controller, "site:5", node); name != "plug (5)" {
+		t.Error(name)
+	}
+	c.deviceRegisterSet("site:5", mgw.DeviceInfo{Name: "kitchen plug"})
+	if name := c.resolveDeviceName(controller, "site:5", node); name != "plug (5)" {
+		t.Error("expected rendered name for empty gateway name", name)
+	}
+	c.nameTemplate, _ = parseNameTemplate("{{.Location}} {{.Product}}")
+	node.Location = "hall"
+	if name := c.resolveDeviceName(controller, "site:5", node); name != "hall plug" {
+		t.Error("expected changed template result for known device", name)
+	}
+	c.nameTemplate = nil
+	node.Name = "gateway plug"
+	if name := c.resolveDeviceName(controller, "site:5", node); name != "gateway plug" {
+		t.Error(name)
+	}
+
+	file := filepath.Join(t.TempDir(), "fallback.json")
+	c.store, _ = fallback.NewFallback(file)
+	endpoint := model.DeviceInfo{NodeId: 5, Endpoint: 2, Name: "gateway plug 2"}
+	c.setStoredDeviceName("site:5.2", &storedDeviceName{Name: "kitchen light", Rendered: "gateway plug 2"})
+	restarted := &Connector{controllers: []*Controller{controller}}
+	restarted.store, _ = fallback.NewFallback(file)
+	if name := restarted.resolveDeviceName(controller, "site:5.2", endpoint); name != "kitchen light" {
+		t.Error("expected stored rename after restart", name)
+	}
+	endpoint.Name = "hall plug 2"
+	restarted.config.NameConflictWinner = NameConflictWinnerMgw
+	if name := restarted.resolveDeviceName(controller, "site:5.2", endpoint); name != "kitchen light" {
+		t.Error("expected mgw name to win", name)
+	}
+	restarted.config.NameConflictWinner = NameConflictWinnerGateway
+	if name := restarted.resolveDeviceName(controller, "site:5.2", endpoint); name != "hall plug 2" {
+		t.Error("expected gateway name to win after a gateway side change", name)
+	}
+	if _, ok := restarted.getStoredDeviceName("site:5.2"); ok {
+		t.Error("expected discarded rename")
+	}
+
+	if !c.markNodeNamePushed("site:5", "a") || c.markNodeNamePushed("site:5", "a") || !c.markNodeNamePushed("site:5", "b") {
+		t.Error("expected one push per name change")
+	}
+}
+
+func TestRenderDeviceName(t *testing.T) {
+	tmpl, err := parseNameTemplate("{{if .Location}}{{.Location}} – {{end}}{{or .Name .Product}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &Connector{nameTemplate: tmpl}
+	node := model.DeviceInfo{NodeId: 5, Product: "Thermostat", Location: "Kitchen"}
+	if name := c.renderDeviceName(node); name != "Kitchen – Thermostat" {
+		t.Error(name)
+	}
+	node.Location = ""
+	node.Name = "radiator"
+	if name := c.renderDeviceName(node); name != "radiator" {
+		t.Error(name)
+	}
+	c.nameTemplate, _ = parseNameTemplate("{{.Location}}")
+	if name := c.renderDeviceName(node); name != "Thermostat (5)" {
+		t.Error(name)
+	}
+	if _, err = parseNameTemplate("{{.Location"); err == nil {
+		t.Error("expected parse error")
+	}
+}
+
+func TestFilters(t *testing.T) {
+	c := &Connector{config: configuration.Config{Filters: configuration.Filters{
+		Allow: []configuration.FilterRule{{NodeIds: []int64{5}, CommandClasses: []int64{112}}},
+		Deny: []configuration.FilterRule{
+			{NodeIds: []int64{6}},
+			{MappingKeys: []string{"1.2.3"}, Properties: []string{"currentValue"}},
+		},
+	}}}
+	node5 := model.DeviceInfo{NodeId: 5, ManufacturerId: "1", ProductType: "2", ProductId: "3"}
+	node7 := model.DeviceInfo{NodeId: 7}
+	if !c.nodeIsExposed(node5) || c.nodeIsExposed(model.DeviceInfo{NodeId: 6}) || !c.nodeIsExposed(node7) {
+		t.Error("unexpected node exposure")
+	}
+	expected := []struct {
+		node   model.DeviceInfo
+		value  model.Value
+		expose bool
+	}{
+		{node5, model.Value{ClassId: 37, Property: "targetValue"}, true},
+		{node5, model.Value{ClassId: 37, Property: "currentValue"}, false},
+		{node5, model.Value{ClassId: 112, Property: float64(3)}, true},
+		{node7, model.Value{ClassId: 112, Property: float64(3)}, false},
+		{node7, model.Value{ClassId: 32, Property: "currentValue"}, false},
+		{node7, model.Value{ClassId: 37, Property: "currentValue"}, true},
+	}
+	for i, e := range expected {
+		if c.valueIsExposed(e.node, e.value) != e.expose {
+			t.Error(i, e)
+		}
+	}
+}
+
+func TestServiceIdMigration(t *testing.T) {
+	m := newServiceIdMigrator([]configuration.ServiceIdMigration{{CommandClass: 49, Index: 1, Property: "Temperature"}})
+	legacy := []struct {
+		current string
+		legacy  string
+	}{
+		{"37-0-currentValue:get", "37-1-0:get"},
+		{"37-1-targetValue", "37-2-0"},
+		{"50-0-value-66049:get", "50-1-2:get"},
+		{"49-0-Temperature:get", "49-1-1:get"},
+	}
+	for _, e := range legacy {
+		if result, ok := m.LegacyServiceId(e.current); !ok || result != e.legacy {
+			t.Error(e, result, ok)
+		}
+	}
+	if _, ok := m.LegacyServiceId("112-0-3"); ok {
+		t.Error("expected unmapped service")
+	}
+	if result, ok := m.CurrentServiceId("37-1-0"); !ok || result != "37-0-targetValue" {
+		t.Error(result, ok)
+	}
+	if result, ok := m.CurrentServiceId("37-1-0:get"); !ok || result != "37-0-currentValue:get" {
+		t.Error(result, ok)
+	}
+	if _, ok := m.CurrentServiceId("37-0-targetValue"); ok {
+		t.Error("expected unmapped service")
+	}
+	if !m.markUnmapped("112-0-3") || m.markUnmapped("112-0-3") {
+		t.Error("unmapped services should be reported once")
+	}
+}
+
+func TestLegacyEventsOnlyForZwavejs2mqtt(t *testing.T) {
+	legacyController := &Controller{deviceIdPrefix: "old", config: configuration.ControllerConfig{ZwaveController: "zwave2mqtt"}}
+	controller := &Controller{deviceIdPrefix: "new", config: configuration.ControllerConfig{ZwaveController: "zwavejs2mqtt"}}
+	c, broker := newTestConnector(configuration.Config{ServiceIdTransition: true}, nil, legacyController, controller)
+	c.sendLegacyEvent(legacyController, "old:5", "37-0-currentValue:get", true)
+	c.sendLegacyEvent(controller, "new:5", "37-0-currentValue:get", true)
+	if len(broker.messages("event/old:5/37-1-0:get")) != 0 {
+		t.Error("unexpected legacy event for zwave2mqtt controller")
+	}
+	if len(broker.messages("event/new:5/37-1-0:get")) != 1 {
+		t.Error(broker.published)
+	}
+	if len(c.serviceIdMigrator.unmapped) != 0 {
+		t.Error(c.serviceIdMigrator.unmapped)
+	}
+}
+
+func TestValueSemantics(t *testing.T) {
+	c := &Connector{config: configuration.Config{
+		SemanticRules: []configuration.SemanticRule{{CommandClass: 49, Property: "Air temperature", MeasuringFunction: "urn:infai:ses:measuring-function:custom"}},
+		SemanticIds:   map[string]string{"getOnOffState": "f1", "setOnOffState": "f2", "device": "a1", "boolean": "c1", "getEnergyConsumption": "f3"},
+	}}
+	expected := []struct {
+		value  model.Value
+		get    bool
+		result valueSemantics
+		ok     bool
+	}{
+		{model.Value{ClassId: 37, Property: "currentValue"}, true, valueSemantics{"f1", "a1", "c1"}, true},
+		{model.Value{ClassId: 37, Property: "targetValue"}, false, valueSemantics{"f2", "a1", "c1"}, true},
+		{model.Value{ClassId: 37, Property: "targetValue"}, true, valueSemantics{}, false},
+		{model.Value{ClassId: 50, Property: "value", PropertyKey: float64(65537)}, true, valueSemantics{FunctionId: "f3"}, true},
+		{model.Value{ClassId: 50, Property: "value", PropertyKey: float64(66049)}, true, valueSemantics{}, false},
+		{model.Value{ClassId: 49, Property: "Air temperature", Unit: "°C"}, true, valueSemantics{FunctionId: "urn:infai:ses:measuring-function:custom"}, true},
+	}
+	for i, e := range expected {
+		result, ok := c.getValueSemantics(e.value, e.get)
+		if ok != e.ok || result != e.result {
+			t.Error(i, result, ok)
+		}
+	}
+}
+
+type semanticTestRepo struct {
+	DeviceRepo
+}
+
+func (semanticTestRepo) GetSemanticIds() (map[string]string, error) {
+	return map[string]string{"gettemperature": "f1", "air": "a1", "celsius": "c1"}, nil
+}
+
+func TestValueSemanticsByPlatformNames(t *testing.T) {
+	c := &Connector{devicerepo: semanticTestRepo{}, config: configuration.Config{SemanticIds: map[string]string{"celsius": "c2"}}}
+	result, ok := c.getValueSemantics(model.Value{ClassId: 49, Property: "Air temperature", Unit: "°C"}, true)
+	if !ok || result != (valueSemantics{"f1", "a1", "c2"}) {
+		t.Error(result, ok)
+	}
+	if _, ok = c.getValueSemantics(model.Value{ClassId: 49, Property: "Humidity", Unit: "%"}, true); ok {
+		t.Error("expected unresolved function to be skipped")
+	}
+}
+
+type syncTestRepo struct {
+	DeviceRepo
+	deviceTypes map[string]models.DeviceType
+	updates     []models.DeviceType
+}
+
+func (this *syncTestRepo) GetDeviceType(id string) (models.DeviceType, error) {
+	return this.deviceTypes[id], nil
+}
+
+func (this *syncTestRepo) UpdateDeviceType(dt models.DeviceType, _ []string) (models.DeviceType, int, error) {
+	this.updates = append(this.updates, dt)
+	return dt, 200, nil
+}
+
+func TestUpdateDeviceTypeServices(t *testing.T) {
+	repo := &syncTestRepo{deviceTypes: map[string]models.DeviceType{
+		"created": {Id: "created", Name: "UNFINISHED zwavejs2mqtt Fibaro Plug", Services: []models.Service{{LocalId: "37-0-currentValue:get"}}},
+		"curated": {Id: "curated", Name: "Fibaro Plug", Services: []models.Service{{LocalId: "37-0-currentValue:get"}}},
+	}}
+	c := &Connector{devicerepo: repo, config: configuration.Config{}}
+	node := model.DeviceInfo{NodeId: 5, Values: map[string]model.Value{
+		"a": {ComputedServiceId: "37-0-currentValue", Type: "boolean", ReadOnly: true},
+		"b": {ComputedServiceId: "50-0-value-65537", Type: "number", ReadOnly: true},
+	}}
+
+	missing, updated, err := c.updateDeviceTypeServices(node, "curated")
+	if err != nil || updated || !slices.Equal(missing, []string{"50-0-value-65537:get"}) || len(repo.updates) != 0 {
+		t.Error(missing, updated, err)
+	}
+
+	missing, updated, err = c.updateDeviceTypeServices(node, "created")
+	if err != nil || !updated || !slices.Equal(missing, []string{"50-0-value-65537:get"}) || len(repo.updates) != 1 {
+		t.Error(missing, updated, err)
+		return
+	}
+	if services := repo.updates[0].Services; len(services) != 2 || services[1].LocalId != "50-0-value-65537:get" {
+		t.Error(services)
+	}
+
+	c.reportedDeviceTypeGaps = map[string]string{}
+	if !c.markDeviceTypeGapReported("curated", missing) || c.markDeviceTypeGapReported("curated", missing) {
+		t.Error("missing services should be reported once")
+	}
+
+	c.deviceTypeSyncs = map[string]bool{}
+	key := getDeviceTypeSyncKey("curated", node)
+	if !c.startDeviceTypeSync(key) || c.startDeviceTypeSync(key) {
+		t.Error("device type should be checked once per set of value services")
+	}
+	node.Values["c"] = model.Value{ComputedServiceId: "49-0-Air_temperature", Type: "number", ReadOnly: true}
+	if key == getDeviceTypeSyncKey("curated", node) {
+		t.Error("changed value services should be checked again")
+	}
+	c.resetDeviceTypeSync(key)
+	if !c.startDeviceTypeSync(key) {
+		t.Error("failed checks should be retried")
+	}
+}
+
+func TestGenerateDeviceTypes(t *testing.T) {
+	payload, err := os.ReadFile("../tests/resources/example_getNodes.json")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	c0 := &Connector{}
+	nodes, _, _, err := zwavejs2mqtt.ParseGetNodesResult(payload)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result := GenerateDeviceTypes(configuration.Config{}, nodes)
+	dt, ok := result["881.3.2"]
+	if len(result) != 1 || !ok {
+		t.Error(result)
+		return
+	}
+	if !strings.HasPrefix(dt.Name, "UNFINISHED") || len(dt.Services) <= len(c0.getDeviceServiceDefinitions())+1 {
+		t.Error(dt.Name, len(dt.Services))
+	}
+	denied := configuration.Config{Filters: configuration.Filters{Deny: []configuration.FilterRule{{NodeIds: []int64{2}}}}}
+	if result = GenerateDeviceTypes(denied, nodes); len(result) != 0 {
+		t.Error("denied node should not be generated", result)
+	}
+}
+
+func TestCanonicalTypeMappingKey(t *testing.T) {
+	expected := map[string]string{
+		"271.4609.4096":                        "271.4609.4096",
+		"0x010f.0x1201.0x1000":                 "271.4609.4096",
+		"0x010f.0x1201.271-4096-4609":          "271.4609.4096",
+		" 0x0002.0x0005.0x003 ":                "2.5.3",
+		"271.4609.4096.2":                      "271.4609.4096.2",
+		"ManufacturerId.ProductType.ProductId": "ManufacturerId.ProductType.ProductId",
+		"urn:infai:ses:device-type:1":          "urn:infai:ses:device-type:1",
+	}
+	for key, e := range expected {
+		if result := model.CanonicalTypeMappingKey(key); result != e {
+			t.Error(key, result, e)
+		}
+	}
+	mapping := canonicalizeMappingKeys(map[string]string{"0x010f.0x1201.0x1000": "dt1", "271.4609.4096": "dt1", "0x0002.0x0005.0x0003": "dt2"}, (&configuration.Config{}).GetLogger())
+	if len(mapping) != 2 || mapping["271.4609.4096"] != "dt1" || mapping["2.5.3"] != "dt2" {
+		t.Error(mapping)
+	}
+	c := &Connector{deviceTypeMapping: mapping}
+	dt, err := c.provideDeviceTypeId(model.DeviceInfo{ManufacturerId: "0x0002", ProductType: "0x0005", ProductId: "2-3-5"})
+	if err != nil || dt != "dt2" {
+		t.Error(dt, err)
+	}
+}
+
+func TestDeviceTypeRules(t *testing.T) {
+	rules, err := parseDeviceTypeRules([]configuration.DeviceTypeRule{
+		{Manufacturer: "0x010f", ProductType: "0x0600-0x06ff", DeviceTypeId: "wallplug"},
+		{Manufacturer: "271", ProductType: "0x0600-0x06ff", FirmwareVersion: "3.x", DeviceTypeId: "invalid"},
+	})
+	if err == nil {
+		t.Error("expected invalid pattern error")
+	}
+	rules, err = parseDeviceTypeRules([]configuration.DeviceTypeRule{
+		{Manufacturer: "0x010f", ProductType: "0x0600-0x06ff", DeviceTypeId: "wallplug"},
+		{Manufacturer: "271", ProductType: "1538", FirmwareVersion: "3.2-4.0", Priority: 1, DeviceTypeId: "wallplug-new"},
+		{Manufacturer: "*", Endpoint: "1-2", DeviceTypeId: "endpoint"},
+		{EndpointCount: "2", CommandClasses: []int64{37, 50}, DeviceTypeId: "double-switch"},
+		{FirmwareVersion: "1.*", DeviceTypeId: "old"},
+		{Manufacturer: "9", CommandClasses: []int64{112, 134}, DeviceTypeId: "configurable"},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	c := &Connector{deviceTypeRules: rules}
+	switchValues := map[string]model.Value{"a": {ClassId: 37}, "b": {ClassId: 50}}
+	expected := []struct {
+		node   model.DeviceInfo
+		result string
+	}{
+		{model.DeviceInfo{ManufacturerId: "271", ProductType: "1538", ProductId: "4099", FirmwareVersion: "3.2.1"}, "wallplug-new"},
+		{model.DeviceInfo{ManufacturerId: "0x010f", ProductType: "0x0602", ProductId: "0x1000", FirmwareVersion: "4.1"}, "wallplug"},
+		{model.DeviceInfo{ManufacturerId: "0x010f", ProductType: "0x0602", ProductId: "0x1000", Endpoint: 2}, "endpoint"},
+		{model.DeviceInfo{ManufacturerId: "2", ProductType: "5", ProductId: "3", Endpoints: []int64{1, 2}, Values: switchValues}, "double-switch"},
+		{model.DeviceInfo{ManufacturerId: "2", ProductType: "5", ProductId: "3", Endpoints: []int64{1}, Values: switchValues}, ""},
+		{model.DeviceInfo{ManufacturerId: "2", ProductType: "5", ProductId: "3", FirmwareVersion: "1.12"}, "old"},
+		{model.DeviceInfo{ManufacturerId: "2", ProductType: "5", ProductId: "3", Endpoint: 3}, ""},
+	}
+	for i, e := range expected {
+		result, _ := c.findDeviceTypeByRules(e.node)
+		if result != e.result {
+			t.Error(i, result, e.result)
+		}
+	}
+
+	hidden := c.filterNodeValues(model.DeviceInfo{ManufacturerId: "9", ProductType: "1", ProductId: "1", Values: map[string]model.Value{"a": {ClassId: 112}, "b": {ClassId: 134}, "c": {ClassId: 37}}})
+	if len(hidden.Values) != 1 {
+		t.Error("expected hidden configuration and version values", hidden.Values)
+	}
+	if result, _ := c.findDeviceTypeByRules(hidden); result != "configurable" {
+		t.Error("rules should match command classes hidden by filters", result)
+	}
+}
+
+func TestLoadTypeMappingFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mapping.yaml")
+	err := os.WriteFile(file, []byte("device_type_mapping:\n  \"0x010f.0x1201.0x1000\": urn:infai:ses:device-type:file\nnode_device_type_overwrite:\n  \"5\": urn:infai:ses:device-type:node\n"), 0644)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	c := &Connector{config: configuration.Config{
+		DeviceTypeMappingFile: file,
+		DeviceTypeMapping:     map[string]string{"271.4609.4096": "config", "2.5.3": "config"},
+	}}
+	changed, err := c.loadTypeMappingFile()
+	if err != nil || !changed {
+		t.Error(changed, err)
+		return
+	}
+	mapping, overwrite := c.getTypeMappings()
+	if mapping["271.4609.4096"] != "urn:infai:ses:device-type:file" || mapping["2.5.3"] != "config" || overwrite["5"] != "urn:infai:ses:device-type:node" {
+		t.Error(mapping, overwrite)
+	}
+	if changed, err = c.loadTypeMappingFile(); err != nil || changed {
+		t.Error("unchanged file should not be reloaded", changed, err)
+	}
+
+	err = os.WriteFile(file, []byte("device_type_mapping: ["), 0644)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err = c.loadTypeMappingFile(); err == nil {
+		t.Error("expected parse error")
+	}
+	if mapping, _ = c.getTypeMappings(); mapping["271.4609.4096"] != "urn:infai:ses:device-type:file" {
+		t.Error("invalid file should keep the current mapping", mapping)
+	}
+}
+
+type testToken struct{}
+
+func (testToken) Wait() bool                     { return true }
+func (testToken) WaitTimeout(time.Duration) bool { return true }
+func (testToken) Error() error                   { return nil }
+func (testToken) Done() <-chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+// testMqtt replaces the mgw broker; published messages are recorded by topic
+type testMqtt struct {
+	paho.Client
+	mux       sync.Mutex
+	published map[string][]string
+	handlers  map[string]paho.MessageHandler
+}
+
+func (this *testMqtt) IsConnected() bool {
+	return true
+}
+
+func (this *testMqtt) Publish(topic string, _ byte, _ bool, payload interface{}) paho.Token {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if b, ok := payload.([]byte); ok {
+		payload = string(b)
+	}
+	this.published[topic] = append(this.published[topic], payload.(string))
+	return testToken{}
+}
+
+func (this *testMqtt) Subscribe(topic string, _ byte, handler paho.MessageHandler) paho.Token {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.handlers[topic] = handler
+	return testToken{}
+}
+
+func (this *testMqtt) Unsubscribe(topics ...string) paho.Token {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	for _, topic := range topics {
+		delete(this.handlers, topic)
+	}
+	return testToken{}
+}
+
+func (this *testMqtt) messages(topic string) []string {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return slices.Clone(this.published[topic])
+}
+
+func (this *testMqtt) deviceUpdates() (result []mgw.DeviceInfoUpdate) {
+	for _, msg := range this.messages(mgw.DeviceManagerTopic + "/test") {
+		update := mgw.DeviceInfoUpdate{}
+		_ = json.Unmarshal([]byte(msg), &update)
+		result = append(result, update)
+	}
+	return result
+}
+
+func newTestConnector(config configuration.Config, repo DeviceRepo, controllers ...*Controller) (*Connector, *testMqtt) {
+	config.ConnectorId = "test"
+	broker := &testMqtt{published: map[string][]string{}, handlers: map[string]paho.MessageHandler{}}
+	for _, controller := range controllers {
+		if controller.pendingInterviews == nil {
+			controller.pendingInterviews = map[int64]bool{}
+		}
+		if controller.husks == nil {
+			controller.husks = map[int64]huskRecoveryState{}
+		}
+	}
+	return &Connector{
+		config:                 config,
+		mgwClient:              mgw.NewWithMqttClient(config, broker),
+		controllers:            controllers,
+		deviceRegister:         map[string]mgw.DeviceInfo{},
+		valueStore:             map[string]interface{}{},
+		nodeStore:              map[string]model.DeviceInfo{},
+		deviceGroups:           map[string]configuration.DeviceGroupConfig{},
+		connectorId:            config.ConnectorId,
+		serviceIdMigrator:      newServiceIdMigrator(config.ServiceIdMigrations),
+		reportedDeviceTypeGaps: map[string]string{},
+		deviceTypeSyncs:        map[string]bool{},
+		devicerepo:             repo,
+	}, broker
+}
+
+type listenerTestRepo struct {
+	DeviceRepo
+	err error
+}
+
+func (this *listenerTestRepo) FindDeviceTypeId(model.DeviceInfo) (string, bool, error) {
+	return "repo", false, this.err
+}
+
+func TestNodeAvailableEventKeepsDeviceType(t *testing.T) {
+	rules, err := parseDeviceTypeRules([]configuration.DeviceTypeRule{
+		{FirmwareVersion: "2.*", EndpointCount: "0", CommandClasses: []int64{112}, DeviceTypeId: "rule"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	controller := &Controller{deviceIdPrefix: "site"}
+	c, broker := newTestConnector(configuration.Config{}, &listenerTestRepo{}, controller)
+	c.deviceTypeRules = rules
+	identification := model.DeviceInfo{NodeId: 5, Name: "plug", ManufacturerId: "271", ProductType: "1538", ProductId: "4099"}
+	full := identification
+	full.FirmwareVersion = "2.1"
+	full.Values = map[string]model.Value{"5-112-0-1": {NodeId: 5, ClassId: 112, Property: float64(1)}}
+
+	c.DeviceInfoListener(controller, []model.DeviceInfo{full}, nil, true, true)
+	c.DeviceInfoListener(controller, []model.DeviceInfo{identification}, nil, false, false)
+
+	updates := broker.deviceUpdates()
+	if len(updates) != 2 {
+		t.Fatal(updates)
+	}
+	for _, update := range updates {
+		if update.DeviceId != "site:5" || update.Data.DeviceType != "rule" {
+			t.Error(update)
+		}
+	}
+}
+
+func TestDeferredDeviceIsNotMissing(t *testing.T) {
+	controller := &Controller{deviceIdPrefix: "site"}
+	repo := &listenerTestRepo{}
+	c, broker := newTestConnector(configuration.Config{}, repo, controller)
+	c.deleteMissingDevices = true
+	node := model.DeviceInfo{NodeId: 5, ManufacturerId: "271", ProductType: "1538", ProductId: "4099", Values: map[string]model.Value{}}
+
+	c.DeviceInfoListener(controller, []model.DeviceInfo{node}, nil, true, true)
+	repo.err = fmt.Errorf("%w: test", devicerepo.ErrDeviceTypeListPending)
+	c.DeviceInfoListener(controller, []model.DeviceInfo{node}, nil, true, true)
+
+	updates := broker.deviceUpdates()
+	if len(updates) != 1 || updates[0].Method != "set" {
+		t.Error("deferred device should keep its registration", updates)
+	}
+	if _, ok := c.deviceRegisterGet("site:5"); !ok || !c.takeDeferredNodes()[controller][5] {
+		t.Error("expected registered and deferred device")
+	}
+}
+
+type controllerDeviceTestClient struct {
+	Z2mClient
+	healedNodes []int64
+	err         error
+}
+
+func (this *controllerDeviceTestClient) GetControllerNodeId() int64 {
+	return model.DefaultControllerNodeId
+}
+
+func (this *controllerDeviceTestClient) HealNode(nodeId int64) (interface{}, error) {
+	this.healedNodes = append(this.healedNodes, nodeId)
+	return "started", this.err
+}
+
+func TestControllerDeviceRegistration(t *testing.T) {
+	controller := &Controller{deviceIdPrefix: "site", client: &controllerDeviceTestClient{}}
+	c, broker := newTestConnector(configuration.Config{}, &listenerTestRepo{}, controller)
+	c.DeviceInfoListener(controller, []model.DeviceInfo{}, nil, true, true)
+	if updates := broker.deviceUpdates(); len(updates) != 0 {
+		t.Error("controller device without controller_device_type_id", updates)
+	}
+
+	c.config.ControllerDeviceTypeId = "urn:infai:ses:device-type:controller"
+	c.DeviceInfoListener(controller, []model.DeviceInfo{}, nil, true, false)
+	if updates := broker.deviceUpdates(); len(updates) != 0 {
+		t.Error("controller device should only be registered with all known devices", updates)
+	}
+	c.DeviceInfoListener(controller, []model.DeviceInfo{}, nil, true, true)
+	updates := broker.deviceUpdates()
+	if len(updates) != 1 || updates[0].Method != "set" || updates[0].DeviceId != "site:controller" || updates[0].Data.DeviceType != "urn:infai:ses:device-type:controller" || updates[0].Data.State != mgw.Online {
+		t.Error(updates)
+	}
+	if _, ok := broker.handlers["command/site:controller/+"]; !ok {
+		t.Error("expected command subscription of controller device")
+	}
+
+	//the controller device is not missing in following device lists
+	c.deleteMissingDevices = true
+	c.DeviceInfoListener(controller, []model.DeviceInfo{}, nil, true, true)
+	for _, update := range broker.deviceUpdates() {
+		if update.Method != "set" {
+			t.Error(update)
+		}
+	}
+}
+
+func TestControllerDeviceCommands(t *testing.T) {
+	client := &controllerDeviceTestClient{}
+	controller := &Controller{deviceIdPrefix: "site", client: client}
+	c, broker := newTestConnector(configuration.Config{}, &listenerTestRepo{}, controller)
+
+	c.CommandHandler("site:controller", ControllerServiceHealNode, mgw.Command{CommandId: "c1", Data: `{"nodeId": 5}`})
+	if !slices.Equal(client.healedNodes, []int64{5}) {
+		t.Error(client.healedNodes)
+	}
+	responses := broker.messages("response/site:controller/" + ControllerServiceHealNode)
+	if len(responses) != 1 || !strings.Contains(responses[0], `"command_id":"c1"`) || !strings.Contains(responses[0], `\"started\"`) {
+		t.Error(responses)
+	}
+
+	c.CommandHandler("site:controller", "unknown", mgw.Command{CommandId: "c2"})
+	c.CommandHandler("site:controller", ControllerServiceHealNode, mgw.Command{CommandId: "c3", Data: "{"})
+	client.err = errors.New("test")
+	c.CommandHandler("site:controller", ControllerServiceHealNode, mgw.Command{CommandId: "c4", Data: `{"nodeId": 6}`})
+	for _, id := range []string{"c2", "c3", "c4"} {
+		if len(broker.messages("error/command/"+id)) != 1 {
+			t.Error("expected command error", id, broker.published)
+		}
+	}
+	if !slices.Equal(client.healedNodes, []int64{5, 6}) {
+		t.Error(client.healedNodes)
+	}
+}
+
+func TestGetConfigParameters(t *testing.T) {
+	node := model.DeviceInfo{NodeId: 5, Values: map[string]model.Value{
+		"5-112-0-3-65280":     {ClassId: 112, Property: float64(3), PropertyKey: float64(65280), Label: "high byte", Value: float64(1)},
+		"5-112-0-3-255":       {ClassId: 112, Property: float64(3), PropertyKey: float64(255), Label: "low byte", Value: float64(2)},
+		"5-112-0-1":           {ClassId: 112, Property: float64(1), Label: "led", Value: true, ReadOnly: true},
+		"5-112-1-2":           {ClassId: 112, Property: "Wake up interval", Index: 2, Label: "zwave2mqtt", Value: float64(3600)},
+		"5-37-0-currentValue": {ClassId: 37, Property: "currentValue", Value: true},
+	}}
+	result := getConfigParameters(node)
+	if len(result) != 4 {
+		t.Fatal(result)
+	}
+	expected := []struct {
+		parameter int64
+		bitMask   int64 //0 for parameters without bit mask
+		label     string
+	}{
+		{1, 0, "led"},
+		{2, 0, "zwave2mqtt"},
+		{3, 255, "low byte"},
+		{3, 65280, "high byte"},
+	}
+	for i, e := range expected {
+		param := result[i]
+		if param.Parameter != e.parameter || param.Label != e.label || (e.bitMask == 0) != (param.BitMask == nil) || (param.BitMask != nil && *param.BitMask != e.bitMask) {
+			t.Error(i, param)
+		}
+	}
+	if !result[0].ReadOnly || result[1].Value != float64(3600) {
+		t.Error(result)
+	}
+}
+
+type configProfileTestClient struct {
+	Z2mClient
+	failing map[int64]bool
+	set     []int64
+}
+
+func (this *configProfileTestClient) SetConfigParameter(_ int64, parameter int64, _ *int64, _ interface{}) (interface{}, error) {
+	this.set = append(this.set, parameter)
+	if this.failing[parameter] {
+		return nil, errors.New("rejected")
+	}
+	return "ok", nil
+}
+
+func TestApplyConfigProfile(t *testing.T) {
+	bitMask := int64(255)
+	client := &configProfileTestClient{failing: map[int64]bool{2: true}}
+	controller := &Controller{deviceIdPrefix: "site", client: client}
+	c := &Connector{config: configuration.Config{ConfigParameterProfiles: map[string][]configuration.ConfigParameterSetting{
+		"271.4609.4096": {{Parameter: 1, Value: 10}, {Parameter: 2, Value: 20}, {Parameter: 3, BitMask: &bitMask, Value: 30}},
+	}}}
+
+	result, err := c.applyConfigProfile(controller, 5, "0x010f.0x1201.0x1000")
+	if !slices.Equal(client.set, []int64{1, 2, 3}) {
+		t.Error("all parameters should be tried", client.set)
+	}
+	if err == nil || !strings.Contains(err.Error(), "parameter 2: rejected") || strings.Contains(err.Error(), "parameter 1") {
+		t.Error(err)
+	}
+	if len(result) != 2 || result[0].Parameter != 1 || result[1].Parameter != 3 || *result[1].BitMask != 255 || result[1].Result != "ok" {
+		t.Error(result)
+	}
+
+	if _, err = c.applyConfigProfile(controller, 5, "1.2.3"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+type maintenanceTestClient struct {
+	Z2mClient
+	calls           []string
+	err             error
+	associations    []model.Association
+	infoUpdates     int
+	lifelineRepairs []model.AssociationAddress
+}
+
+func (this *maintenanceTestClient) call(name string, nodeId int64) {
+	this.calls = append(this.calls, fmt.Sprintf("%v:%v", name, nodeId))
+}
+
+func (this *maintenanceTestClient) GetControllerNodeId() int64 {
+	return 3
+}
+
+func (this *maintenanceTestClient) PingNode(nodeId int64) (interface{}, error) {
+	this.call("ping", nodeId)
+	return true, this.err
+}
+
+func (this *maintenanceTestClient) RefreshValues(nodeId int64) (interface{}, error) {
+	this.call("refreshValues", nodeId)
+	return nil, this.err
+}
+
+func (this *maintenanceTestClient) RefreshInfo(nodeId int64) (interface{}, error) {
+	this.call("refreshInfo", nodeId)
+	return nil, this.err
+}
+
+func (this *maintenanceTestClient) RequestDeviceInfoUpdate() error {
+	this.infoUpdates++
+	return nil
+}
+
+func (this *maintenanceTestClient) CheckLifelineHealth(nodeId int64, rounds int64) (interface{}, error) {
+	this.call(fmt.Sprintf("checkLifelineHealth(%v)", rounds), nodeId)
+	return map[string]interface{}{"rating": 9, "results": []interface{}{}}, this.err
+}
+
+func (this *maintenanceTestClient) GetAssociations(nodeId int64) ([]model.Association, error) {
+	this.call("getAssociations", nodeId)
+	return this.associations, this.err
+}
+
+func (this *maintenanceTestClient) AddAssociations(source model.AssociationAddress, groupId int64, targets []model.AssociationAddress) (interface{}, error) {
+	this.call(fmt.Sprintf("addAssociations(%v)", groupId), source.NodeId)
+	this.lifelineRepairs = append(this.lifelineRepairs, targets...)
+	return nil, this.err
+}
+
+func TestMaintenanceServices(t *testing.T) {
+	client := &maintenanceTestClient{}
+	controller := &Controller{deviceIdPrefix: "site", client: client}
+	c, broker := newTestConnector(configuration.Config{}, &listenerTestRepo{}, controller)
+
+	c.CommandHandler("site:5.2", DeviceServicePing, mgw.Command{CommandId: "c1"})
+	c.CommandHandler("site:5", DeviceServiceRefreshValues, mgw.Command{CommandId: "c2"})
+	c.CommandHandler("site:5", DeviceServiceCheckLifelineHealth, mgw.Command{CommandId: "c3", Data: `{"rounds": 2}`})
+	c.CommandHandler("site:5", DeviceServiceReInterview, mgw.Command{CommandId: "c4"})
+	if !slices.Equal(client.calls, []string{"ping:5", "refreshValues:5", "checkLifelineHealth(2):5", "refreshInfo:5"}) {
+		t.Error(client.calls)
+	}
+	if responses := broker.messages("response/site:5.2/" + DeviceServicePing); len(responses) != 1 || !strings.Contains(responses[0], `"data":"true"`) {
+		t.Error(responses)
+	}
+	health := broker.messages("response/site:5/" + DeviceServiceCheckLifelineHealth)
+	if len(health) != 1 || !strings.Contains(health[0], `\"rating\":9`) {
+		t.Error(health)
+	}
+
+	//the device info is requested again once the re-interview is completed
+	c.handleInterviewCompleted(controller, 6)
+	c.handleInterviewCompleted(controller, 5)
+	c.handleInterviewCompleted(controller, 5)
+	if client.infoUpdates != 1 {
+		t.Error(client.infoUpdates)
+	}
+
+	client.err = errors.New("test")
+	c.CommandHandler("site:5", DeviceServicePing, mgw.Command{CommandId: "c5"})
+	c.CommandHandler("site:5", DeviceServiceCheckLifelineHealth, mgw.Command{CommandId: "c6", Data: "{"})
+	c.CommandHandler("site:5", DeviceServiceReInterview, mgw.Command{CommandId: "c7"})
+	for _, id := range []string{"c5", "c6", "c7"} {
+		if len(broker.messages("error/command/"+id)) != 1 {
+			t.Error("expected command error", id)
+		}
+	}
+	c.handleInterviewCompleted(controller, 5)
+	if client.infoUpdates != 1 {
+		t.Error("failed re-interview should not request a device info update")
+	}
+}
+
+func TestCheckLifelineCommand(t *testing.T) {
+	client := &maintenanceTestClient{associations: []model.Association{{GroupId: 1, NodeId: 1}, {GroupId: 1, Endpoint: 1, NodeId: 3}}}
+	controller := &Controller{deviceIdPrefix: "site", client: client}
+	c, _ := newTestConnector(configuration.Config{}, &listenerTestRepo{}, controller)
+	c.nodeStoreSet(controller, model.DeviceInfo{NodeId: 5, Groups: []model.AssociationGroup{
+		{GroupId: 1, IsLifeline: true},
+		{GroupId: 1, Endpoint: 1, IsLifeline: true},
+	}}, true)
+
+	result, err := c.checkLifelineCommand(controller, "site:5", 5, "")
+	check, _ := result.(LifelineCheckResult)
+	if err != nil || check.Ok || len(check.Groups) != 2 || check.Groups[0].Ok || !check.Groups[1].Ok || check.Groups[0].Repaired {
+		t.Error(check, err)
+	}
+	if len(client.lifelineRepairs) != 0 {
+		t.Error("check without repair should not change associations")
+	}
+
+	result, err = c.checkLifelineCommand(controller, "site:5", 5, `{"repair": true}`)
+	check, _ = result.(LifelineCheckResult)
+	if err != nil || !check.Groups[0].Repaired || check.Groups[1].Repaired {
+		t.Error(check, err)
+	}
+	if len(client.lifelineRepairs) != 1 || client.lifelineRepairs[0].NodeId != 3 {
+		t.Error("the lifeline should be repaired with the controller node", client.lifelineRepairs)
+	}
+}
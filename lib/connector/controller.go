@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/zwave2mqtt"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/zwavejs2mqtt"
+)
+
+// Controller is one z-wave gateway with its own client and device id namespace
+type Controller struct {
+	config         configuration.ControllerConfig
+	client         Z2mClient
+	deviceIdPrefix string
+
+	firmwareUpdate    *firmwareUpdate //at most one firmware update per controller
+	pendingInterviews map[int64]bool  //nodes whose device info is requested again after the interview completed
+	husks             map[int64]huskRecoveryState
+	nodeTaskMux       sync.Mutex
+}
+
+func newController(config configuration.Config, controllerConfig configuration.ControllerConfig, ctx context.Context) (result *Controller, err error) {
+	result = &Controller{
+		config:            controllerConfig,
+		deviceIdPrefix:    controllerConfig.DeviceIdPrefix,
+		pendingInterviews: map[int64]bool{},
+		husks:             map[int64]huskRecoveryState{},
+	}
+	clientConfig := config.ForController(controllerConfig)
+	switch controllerConfig.ZwaveController {
+	case "":
+		fallthrough
+	case "zwave2mqtt":
+		result.client, err = zwave2mqtt.New(clientConfig, ctx)
+	case "zwavejs2mqtt":
+		result.client, err = zwavejs2mqtt.New(clientConfig, ctx)
+	default:
+		err = errors.New("unknown zwave controller")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (this *Connector) initControllers(ctx context.Context) (err error) {
+	prefixes := map[string]bool{}
+	for _, controllerConfig := range this.config.GetControllers() {
+		if prefixes[controllerConfig.DeviceIdPrefix] {
+			return fmt.Errorf("device_id_prefix %v is used by multiple controllers", controllerConfig.DeviceIdPrefix)
+		}
+		prefixes[controllerConfig.DeviceIdPrefix] = true
+		if missing := controllerConfig.MissingTopics(); len(this.config.Controllers) > 0 && len(missing) > 0 {
+			return fmt.Errorf("controller %v: missing %v", controllerConfig.DeviceIdPrefix, strings.Join(missing, ", "))
+		}
+		controller, err := newController(this.config, controllerConfig, ctx)
+		if err != nil {
+			return err
+		}
+		this.controllers = append(this.controllers, controller)
+	}
+	return nil
+}
+
+func (this *Connector) connectControllers() {
+	for _, controller := range this.controllers {
+		c := controller
+		c.client.SetErrorForwardingFunc(this.mgwClient.SendClientError)
+		c.client.SetValueEventListener(func(nodeValue model.Value) {
+			this.ValueEventListener(c, nodeValue)
+		})
+		c.client.SetDeviceInfoListener(func(nodes []model.DeviceInfo, huskIds []int64, withValues bool, allKnownDevices bool) {
+			this.DeviceInfoListener(c, nodes, huskIds, withValues, allKnownDevices)
+		})
+		c.client.SetDeviceStatusListener(func(nodeId int64, online bool) error {
+			return this.SetDeviceState(c, nodeId, online)
+		})
+		c.client.SetControllerEventListener(func(event string, data []interface{}) {
+			this.ControllerEventListener(c, event, data)
+		})
+		c.client.SetNodeEventListener(func(event string, nodeId int64, data []interface{}) {
+			this.NodeEventListener(c, event, nodeId, data)
+		})
+	}
+}
+
+// requests a device info update from every controller; returns the joined errors
+func (this *Connector) requestDeviceInfoUpdates() (err error) {
+	for _, controller := range this.controllers {
+		err = errors.Join(err, controller.client.RequestDeviceInfoUpdate())
+	}
+	return err
+}
+
+// expects ids from mgw (with prefixes and suffixes)
+func (this *Connector) getControllerByDeviceId(deviceId string) (result *Controller, ok bool) {
+	for _, controller := range this.controllers {
+		if controller.ownsDeviceId(deviceId) && (result == nil || len(controller.deviceIdPrefix) > len(result.deviceIdPrefix)) {
+			result = controller
+		}
+	}
+	return result, result != nil
+}
+
+func (this *Controller) ownsDeviceId(deviceId string) bool {
+	return strings.HasPrefix(deviceId, this.deviceIdPrefix+":")
+}
+
+func (this *Controller) nodeIdToDeviceId(nodeId int64) string {
+	return this.addDeviceIdPrefix(strconv.FormatInt(nodeId, 10))
+}
+
+func (this *Controller) addDeviceIdPrefix(rawDeviceId string) string {
+	return this.deviceIdPrefix + ":" + rawDeviceId
+}
+
+func (this *Controller) removeDeviceIdPrefix(deviceId string) string {
+	return strings.Replace(deviceId, this.deviceIdPrefix+":", "", 1)
+}
@@ -0,0 +1,182 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/mgw"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+const ControllerDeviceLocalId = "controller"
+
+// command services of the virtual controller device
+const (
+	ControllerServiceStartInclusion    = "startInclusion"
+	ControllerServiceStopInclusion     = "stopInclusion"
+	ControllerServiceStartExclusion    = "startExclusion"
+	ControllerServiceStopExclusion     = "stopExclusion"
+	ControllerServiceHealNetwork       = "healNetwork"
+	ControllerServiceHealNode          = "healNode"
+	ControllerServiceRemoveFailedNode  = "removeFailedNode"
+	ControllerServiceReplaceFailedNode = "replaceFailedNode"
+	ControllerServiceSoftReset         = "softReset"
+)
+
+// event services of the virtual controller device
+const (
+	ControllerServiceStatus    = "status"
+	ControllerServiceInclusion = "inclusion"
+)
+
+type ControllerCommandInput struct {
+	NodeId   int64                           `json:"nodeId"`
+	Strategy int64                           `json:"strategy"`
+	Options  map[string]interface{}          `json:"options"`
+	Group    configuration.DeviceGroupConfig `json:"group"`
+}
+
+type ControllerEvent struct {
+	Event string        `json:"event"`
+	Data  []interface{} `json:"data"`
+	Time  int64         `json:"time"`
+}
+
+func (this *Controller) getControllerDeviceId() string {
+	return this.addDeviceIdPrefix(ControllerDeviceLocalId)
+}
+
+func (this *Controller) getControllerNodeId() int64 {
+	if this.client == nil {
+		return model.DefaultControllerNodeId
+	}
+	return this.client.GetControllerNodeId()
+}
+
+func (this *Controller) isControllerDeviceId(deviceId string) bool {
+	return deviceId == this.getControllerDeviceId()
+}
+
+// registers the virtual controller device if controller_device_type_id is configured; returns the device id and true if registered
+func (this *Connector) registerControllerDevice(controller *Controller) (id string, info mgw.DeviceInfo, registered bool) {
+	if this.config.ControllerDeviceTypeId == "" || this.config.ControllerDeviceTypeId == "-" {
+		return id, info, false
+	}
+	id = controller.getControllerDeviceId()
+	info = mgw.DeviceInfo{
+		Name:       "Z-Wave Controller (" + controller.deviceIdPrefix + ")",
+		State:      mgw.Online,
+		DeviceType: this.config.ControllerDeviceTypeId,
+	}
+	err := this.registerDevice(id, info)
+	if err != nil {
+		this.config.GetLogger().Error("unable to register controller device", "error", err)
+		this.mgwClient.SendClientError("unable to register controller device: " + err.Error())
+		return id, info, false
+	}
+	return id, info, true
+}
+
+func (this *Connector) ControllerEventListener(controller *Controller, event string, data []interface{}) {
+	deviceId := controller.getControllerDeviceId()
+	if _, ok := this.deviceRegisterGet(deviceId); !ok {
+		this.config.GetLogger().Debug("ignore controller event because the controller device is not registered", "device", deviceId, "event", event)
+		return
+	}
+	serviceId := ControllerServiceStatus
+	if isInclusionEvent(event) {
+		serviceId = ControllerServiceInclusion
+	}
+	err := this.mgwClient.MarshalAndSendEvent(deviceId, serviceId, ControllerEvent{
+		Event: event,
+		Data:  data,
+		Time:  time.Now().UnixMilli(),
+	})
+	if err != nil {
+		this.config.GetLogger().Error("unable to send event", "device", deviceId, "service", serviceId, "error", err)
+		this.mgwClient.SendClientError("unable to send event: " + err.Error())
+	}
+}
+
+func isInclusionEvent(event string) bool {
+	event = strings.ToLower(event)
+	for _, keyword := range []string{"inclusion", "exclusion", "node added", "node removed", "node found", "grant security", "validate dsk", "interview"} {
+		if strings.Contains(event, keyword) || strings.Contains(event, strings.ReplaceAll(keyword, " ", "_")) {
+			return true
+		}
+	}
+	return false
+}
+
+// expects ids from mgw (with prefixes and suffixes)
+func (this *Connector) handleControllerCommand(controller *Controller, deviceId string, serviceId string, command mgw.Command) {
+	input := ControllerCommandInput{}
+	if command.Data != "" {
+		err := json.Unmarshal([]byte(command.Data), &input)
+		if err != nil {
+			this.config.GetLogger().Error("unable to unmarshal controller command data", "device", deviceId, "service", serviceId, "data", command.Data, "error", err)
+			this.mgwClient.SendCommandError(command.CommandId, "unable to unmarshal controller command data: "+err.Error())
+			return
+		}
+	}
+	var result interface{}
+	var err error
+	client := controller.client
+	switch serviceId {
+	case ControllerServiceStartInclusion:
+		result, err = client.StartInclusion(input.Strategy, input.Options)
+	case ControllerServiceStopInclusion:
+		result, err = client.StopInclusion()
+	case ControllerServiceStartExclusion:
+		result, err = client.StartExclusion()
+	case ControllerServiceStopExclusion:
+		result, err = client.StopExclusion()
+	case ControllerServiceHealNetwork:
+		result, err = client.HealNetwork()
+	case ControllerServiceHealNode:
+		result, err = client.HealNode(input.NodeId)
+	case ControllerServiceRemoveFailedNode:
+		result, err = client.RemoveFailedNode(input.NodeId)
+	case ControllerServiceReplaceFailedNode:
+		result, err = client.ReplaceFailedNode(input.NodeId, input.Strategy)
+	case ControllerServiceSoftReset:
+		result, err = client.SoftReset()
+	case ControllerServiceRefreshTopology:
+		result, err = this.refreshTopology(controller)
+	case ControllerServiceApplyConfigProfiles:
+		result, err = this.applyConfigProfiles(controller)
+	case ControllerServiceCreateGroup:
+		result, err = this.createGroupCommand(controller, input.Group)
+	case ControllerServiceDeleteGroup:
+		result, err = this.deleteGroupCommand(controller, input.Group.Name)
+	case ControllerServiceHealth:
+		result = this.getHealth()
+	default:
+		err = fmt.Errorf("unknown controller service %v", serviceId)
+	}
+	if err != nil {
+		this.config.GetLogger().Error("unable to execute controller command", "device", deviceId, "service", serviceId, "error", err)
+		this.mgwClient.SendCommandError(command.CommandId, "unable to execute controller command: "+err.Error())
+		return
+	}
+	this.respondWithResult(deviceId, serviceId, command, result)
+}
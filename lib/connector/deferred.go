@@ -0,0 +1,59 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+func (this *Connector) deferNode(controller *Controller, nodeId int64) {
+	this.deferredNodesMux.Lock()
+	defer this.deferredNodesMux.Unlock()
+	if this.deferredNodes == nil {
+		this.deferredNodes = map[*Controller]map[int64]bool{}
+	}
+	if this.deferredNodes[controller] == nil {
+		this.deferredNodes[controller] = map[int64]bool{}
+	}
+	this.deferredNodes[controller][nodeId] = true
+}
+
+// returns and forgets the deferred nodes
+func (this *Connector) takeDeferredNodes() (result map[*Controller]map[int64]bool) {
+	this.deferredNodesMux.Lock()
+	defer this.deferredNodesMux.Unlock()
+	result = this.deferredNodes
+	this.deferredNodes = nil
+	return result
+}
+
+// registers nodes whose device type could not be resolved before the last device type list refresh
+func (this *Connector) registerDeferredDevices() {
+	for controller, nodeIds := range this.takeDeferredNodes() {
+		nodes := []model.DeviceInfo{}
+		for nodeId := range nodeIds {
+			node, ok := this.nodeStoreGet(controller, controller.nodeIdToDeviceId(nodeId))
+			if ok {
+				nodes = append(nodes, node)
+			}
+		}
+		if len(nodes) > 0 {
+			this.config.GetLogger().Info("register deferred devices", "controller", controller.deviceIdPrefix, "count", len(nodes))
+			this.DeviceInfoListener(controller, nodes, nil, true, false)
+		}
+	}
+}
@@ -17,15 +17,17 @@
 package connector
 
 import (
+	"errors"
 	"fmt"
 	"runtime/debug"
 
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo"
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/mgw"
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
 )
 
 func (this *Connector) NotifyRefresh() {
-	err := this.z2mClient.RequestDeviceInfoUpdate()
+	err := this.requestDeviceInfoUpdates()
 	if err != nil {
 		this.config.GetLogger().Error("unable to request device info update", "error", err)
 		this.mgwClient.SendClientError(err.Error())
@@ -36,48 +38,95 @@ func (this *Connector) NotifyRefresh() {
 	}
 }
 
-func (this *Connector) SetDeviceState(nodeId int64, online bool) error {
-	deviceId := this.nodeIdToDeviceId(nodeId)
-	info, ok := this.deviceRegisterGet(deviceId)
-	if !ok {
+func (this *Connector) SetDeviceState(controller *Controller, nodeId int64, online bool) error {
+	if _, ok := this.deviceRegisterGet(controller.nodeIdToDeviceId(nodeId)); !ok {
 		return fmt.Errorf("unknown device %v", nodeId)
 	}
-	info.State = mgw.Offline
-	if online {
-		info.State = mgw.Online
+	this.nodeStoreSetOnline(controller, nodeId, online)
+	for _, deviceId := range this.getDeviceIdsOfNode(controller, nodeId) {
+		info, ok := this.deviceRegisterGet(deviceId)
+		if !ok {
+			continue
+		}
+		info.State = mgw.Offline
+		if online {
+			info.State = mgw.Online
+		}
+		this.deviceRegisterSet(deviceId, info)
+		err := this.mgwClient.SetDevice(deviceId, info)
+		if err != nil {
+			return err
+		}
 	}
-	this.deviceRegisterSet(deviceId, info)
-	return this.mgwClient.SetDevice(deviceId, info)
+	return nil
 }
 
-func (this *Connector) DeviceInfoListener(nodes []model.DeviceInfo, huskIds []int64, withValues bool, allKnownDevices bool) {
+func (this *Connector) DeviceInfoListener(controller *Controller, nodes []model.DeviceInfo, huskIds []int64, withValues bool, allKnownDevices bool) {
 	deviceInfos := map[string]mgw.DeviceInfo{}
 	for _, node := range nodes {
-		id, info, err := this.nodeToDeviceInfo(node)
-		if err != nil {
-			this.config.GetLogger().Error("unable to create device info for node", "error", err)
+		//node available events have no values, firmware version or endpoints; device type rules and endpoints need the known node
+		node = this.nodeStoreSet(controller, node, withValues)
+		if !this.nodeIsExposed(node) {
+			this.config.GetLogger().Debug("ignore node because of filters", "controller", controller.deviceIdPrefix, "node", node.NodeId)
 			continue
 		}
-		err = this.registerDevice(id, info)
-		if err != nil {
-			this.config.GetLogger().Error("unable to register device", "error", err)
-			this.mgwClient.SendClientError("unable to register device: " + err.Error())
-			return
+		devices, err := this.splitNodeByEndpoints(this.filterNodeValues(node))
+		if errors.Is(err, devicerepo.ErrDeviceTypeListPending) {
+			this.config.GetLogger().Info("defer device registration until the device type list is refreshed", "controller", controller.deviceIdPrefix, "node", node.NodeId)
+			this.deferNode(controller, node.NodeId)
+			for _, id := range this.getDeviceIdsOfNode(controller, node.NodeId) {
+				if registered, ok := this.deviceRegisterGet(id); ok {
+					deviceInfos[id] = registered //not missing; the devices keep their registration until the lookup is done
+				}
+			}
+			continue
 		}
-		deviceInfos[id] = info
-		if withValues {
-			for _, value := range node.Values {
-				this.ValueEventListener(value)
+		for _, device := range devices {
+			id, info, err := this.nodeToDeviceInfo(controller, device)
+			if errors.Is(err, devicerepo.ErrDeviceTypeListPending) {
+				this.config.GetLogger().Info("defer device registration until the device type list is refreshed", "device", id)
+				this.deferNode(controller, node.NodeId)
+				if registered, ok := this.deviceRegisterGet(id); ok {
+					deviceInfos[id] = registered //not missing; the device keeps its registration until the lookup is done
+				}
+				continue
+			}
+			if err != nil {
+				this.config.GetLogger().Error("unable to create device info for node", "error", err)
+				continue
+			}
+			err = this.registerDevice(id, info)
+			if err != nil {
+				this.config.GetLogger().Error("unable to register device", "error", err)
+				this.mgwClient.SendClientError("unable to register device: " + err.Error())
+				return
+			}
+			deviceInfos[id] = info
+			if withValues {
+				for _, value := range device.Values {
+					this.ValueEventListener(controller, value)
+				}
 			}
 		}
-		this.sendStatistics(node)
+		this.sendStatistics(controller, node)
 	}
 	isSetToOfflineOrDeleted := map[string]bool{}
 	if allKnownDevices {
-		isSetToOfflineOrDeleted = this.unregisterMissingDevices(deviceInfos)
+		if id, info, ok := this.registerControllerDevice(controller); ok {
+			deviceInfos[id] = info
+		}
+		for id, info := range this.registerDeviceGroups(controller) {
+			deviceInfos[id] = info
+		}
+		this.sendTopology(controller, nodes)
+		this.updatePollTasks(controller, nodes)
+		isSetToOfflineOrDeleted = this.unregisterMissingDevices(controller, deviceInfos)
+	}
+	if allKnownDevices {
+		huskIds = this.recoverHusks(controller, huskIds)
 	}
 	if this.husksShouldBeDeleted {
-		this.sendDeleteForHusks(huskIds, isSetToOfflineOrDeleted)
+		this.sendDeleteForHusks(controller, huskIds, isSetToOfflineOrDeleted)
 	}
 }
 
@@ -134,9 +183,13 @@ func (this *Connector) deviceRegisterGetAll() (result map[string]mgw.DeviceInfo)
 	return
 }
 
-func (this *Connector) unregisterMissingDevices(infos map[string]mgw.DeviceInfo) (handled map[string]bool) {
+// only devices of the given controller are checked; other controllers report their own nodes
+func (this *Connector) unregisterMissingDevices(controller *Controller, infos map[string]mgw.DeviceInfo) (handled map[string]bool) {
 	handled = map[string]bool{}
 	for id, info := range this.deviceRegisterGetAll() {
+		if owner, ok := this.getControllerByDeviceId(id); !ok || owner != controller {
+			continue
+		}
 		_, found := infos[id]
 		if !found {
 			info.State = mgw.Offline
@@ -164,15 +217,16 @@ func (this *Connector) unregisterMissingDevices(infos map[string]mgw.DeviceInfo)
 				this.mgwClient.SendClientError("unable to stop listening to device commands: " + err.Error())
 			}
 			this.deviceRegisterRemove(id)
+			this.nodeStoreRemove(id)
 			handled[id] = true
 		}
 	}
 	return
 }
 
-func (this *Connector) sendDeleteForHusks(huskIds []int64, alreadyHandled map[string]bool) {
+func (this *Connector) sendDeleteForHusks(controller *Controller, huskIds []int64, alreadyHandled map[string]bool) {
 	for _, huskId := range huskIds {
-		deviceId := this.nodeIdToDeviceId(huskId)
+		deviceId := controller.nodeIdToDeviceId(huskId)
 		if !alreadyHandled[deviceId] {
 			err := this.mgwClient.RemoveDevice(deviceId)
 			if err != nil {
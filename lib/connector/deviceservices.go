@@ -0,0 +1,253 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/mgw"
+	"github.com/SENERGY-Platform/models/go/models"
+)
+
+// reserved connector-level request services, available on every node device
+const (
+	DeviceServiceListConfigParameters = "listConfigParameters"
+	DeviceServiceGetConfigParameter   = "getConfigParameter"
+	DeviceServiceSetConfigParameter   = "setConfigParameter"
+	DeviceServiceApplyConfigProfile   = "applyConfigProfile"
+	DeviceServiceUpdateFirmware       = "updateFirmware"
+	DeviceServiceAbortFirmwareUpdate  = "abortFirmwareUpdate"
+	DeviceServicePing                 = "ping"
+	DeviceServiceRefreshValues        = "refreshValues"
+	DeviceServiceReInterview          = "reInterview"
+	DeviceServiceCheckLifelineHealth  = "checkLifelineHealth"
+	DeviceServiceListAssociations     = "listAssociations"
+	DeviceServiceAddAssociations      = "addAssociations"
+	DeviceServiceRemoveAssociations   = "removeAssociations"
+	DeviceServiceCheckLifeline        = "checkLifeline"
+	DeviceServiceRename               = "rename"
+	DeviceServiceSetLocation          = "setLocation"
+)
+
+// reserved event services, available on every node device
+const (
+	DeviceServiceFirmwareUpdateStatus = "firmwareUpdateStatus"
+)
+
+// data is the raw command data; deviceId is the mgw device id (with prefix) and may be an endpoint device
+type deviceServiceHandler = func(controller *Controller, deviceId string, nodeId int64, data string) (result interface{}, err error)
+
+func (this *Connector) getDeviceServiceHandler(serviceId string) (handler deviceServiceHandler, ok bool) {
+	switch serviceId {
+	case DeviceServiceListConfigParameters:
+		return this.listConfigParametersCommand, true
+	case DeviceServiceGetConfigParameter:
+		return this.getConfigParameterCommand, true
+	case DeviceServiceSetConfigParameter:
+		return this.setConfigParameterCommand, true
+	case DeviceServiceApplyConfigProfile:
+		return this.applyConfigProfileCommand, true
+	case DeviceServiceUpdateFirmware:
+		return this.updateFirmwareCommand, true
+	case DeviceServiceAbortFirmwareUpdate:
+		return this.abortFirmwareUpdateCommand, true
+	case DeviceServicePing:
+		return this.pingCommand, true
+	case DeviceServiceRefreshValues:
+		return this.refreshValuesCommand, true
+	case DeviceServiceReInterview:
+		return this.reInterviewCommand, true
+	case DeviceServiceCheckLifelineHealth:
+		return this.checkLifelineHealthCommand, true
+	case DeviceServiceListAssociations:
+		return this.listAssociationsCommand, true
+	case DeviceServiceAddAssociations:
+		return this.addAssociationsCommand, true
+	case DeviceServiceRemoveAssociations:
+		return this.removeAssociationsCommand, true
+	case DeviceServiceCheckLifeline:
+		return this.checkLifelineCommand, true
+	case DeviceServiceRename:
+		return this.renameCommand, true
+	case DeviceServiceSetLocation:
+		return this.setLocationCommand, true
+	}
+	return nil, false
+}
+
+// expects ids from mgw (with prefixes and suffixes)
+func (this *Connector) handleDeviceServiceCommand(controller *Controller, deviceId string, serviceId string, command mgw.Command, handler deviceServiceHandler) {
+	nodeId, err := controller.deviceIdToNodeId(deviceId)
+	if err != nil {
+		this.config.GetLogger().Error("unable to execute device service command", "device", deviceId, "service", serviceId, "error", err)
+		this.mgwClient.SendCommandError(command.CommandId, "unable to execute device service command: "+err.Error())
+		return
+	}
+	result, err := handler(controller, deviceId, nodeId, command.Data)
+	if err != nil {
+		this.config.GetLogger().Error("unable to execute device service command", "device", deviceId, "service", serviceId, "error", err)
+		this.mgwClient.SendCommandError(command.CommandId, "unable to execute device service command: "+err.Error())
+		return
+	}
+	this.respondWithResult(deviceId, serviceId, command, result)
+}
+
+// service definitions of the reserved services, used for created device types
+func (this *Connector) getDeviceServiceDefinitions() []models.Service {
+	addressVariables := []models.ContentVariable{
+		{Name: "nodeId", Type: models.Integer},
+		{Name: "endpoint", Type: models.Integer},
+	}
+	associationInputs := []models.ContentVariable{
+		{Name: "endpoint", Type: models.Integer},
+		{Name: "groupId", Type: models.Integer},
+		{Name: "targets", Type: models.List, SubContentVariables: []models.ContentVariable{{Name: "*", Type: models.Structure, SubContentVariables: addressVariables}}},
+	}
+	parameterInputs := []models.ContentVariable{
+		{Name: "parameter", Type: models.Integer},
+		{Name: "bitMask", Type: models.Integer},
+	}
+	return []models.Service{
+		this.newDeviceServiceDefinition(DeviceServiceListConfigParameters, "List Configuration Parameters", nil, &models.ContentVariable{
+			Name: "parameters",
+			Type: models.List,
+			SubContentVariables: []models.ContentVariable{{
+				Name: "*",
+				Type: models.Structure,
+				SubContentVariables: []models.ContentVariable{
+					{Name: "parameter", Type: models.Integer},
+					{Name: "bitMask", Type: models.Integer},
+					{Name: "label", Type: models.String},
+					{Name: "description", Type: models.String},
+					{Name: "min", Type: models.Float},
+					{Name: "max", Type: models.Float},
+					{Name: "default", Type: models.Float},
+					{Name: "unit", Type: models.String},
+					{Name: "value", Type: models.Float},
+					{Name: "readOnly", Type: models.Boolean},
+					{Name: "lastUpdate", Type: models.Integer},
+				},
+			}},
+		}),
+		this.newDeviceServiceDefinition(DeviceServiceGetConfigParameter, "Get Configuration Parameter", parameterInputs, &models.ContentVariable{Name: "value", Type: models.Float}),
+		this.newDeviceServiceDefinition(DeviceServiceSetConfigParameter, "Set Configuration Parameter", append(parameterInputs, models.ContentVariable{Name: "value", Type: models.Float}), nil),
+		this.newDeviceServiceDefinition(DeviceServiceApplyConfigProfile, "Apply Configuration Profile", []models.ContentVariable{{Name: "profile", Type: models.String}}, nil),
+		this.newDeviceServiceDefinition(DeviceServiceUpdateFirmware, "Update Firmware", []models.ContentVariable{
+			{Name: "file", Type: models.String},
+			{Name: "target", Type: models.Integer},
+		}, nil),
+		this.newDeviceServiceDefinition(DeviceServiceAbortFirmwareUpdate, "Abort Firmware Update", nil, nil),
+		this.newDeviceServiceDefinition(DeviceServicePing, "Ping", nil, &models.ContentVariable{Name: "result", Type: models.Boolean}),
+		this.newDeviceServiceDefinition(DeviceServiceRefreshValues, "Refresh Values", nil, nil),
+		this.newDeviceServiceDefinition(DeviceServiceReInterview, "Re-Interview", nil, nil),
+		this.newDeviceServiceDefinition(DeviceServiceCheckLifelineHealth, "Check Lifeline Health", []models.ContentVariable{{Name: "rounds", Type: models.Integer}}, &models.ContentVariable{
+			Name: "result",
+			Type: models.Structure,
+			SubContentVariables: []models.ContentVariable{
+				{Name: "rating", Type: models.Integer},
+				{Name: "results", Type: models.List, SubContentVariables: []models.ContentVariable{{Name: "*", Type: models.Structure}}},
+			},
+		}),
+		this.newDeviceServiceDefinition(DeviceServiceListAssociations, "List Associations", nil, &models.ContentVariable{
+			Name: "groups",
+			Type: models.List,
+			SubContentVariables: []models.ContentVariable{{
+				Name: "*",
+				Type: models.Structure,
+				SubContentVariables: []models.ContentVariable{
+					{Name: "groupId", Type: models.Integer},
+					{Name: "endpoint", Type: models.Integer},
+					{Name: "label", Type: models.String},
+					{Name: "maxNodes", Type: models.Integer},
+					{Name: "isLifeline", Type: models.Boolean},
+					{Name: "multiChannel", Type: models.Boolean},
+					{Name: "members", Type: models.List, SubContentVariables: []models.ContentVariable{{Name: "*", Type: models.Structure, SubContentVariables: addressVariables}}},
+				},
+			}},
+		}),
+		this.newDeviceServiceDefinition(DeviceServiceAddAssociations, "Add Associations", associationInputs, nil),
+		this.newDeviceServiceDefinition(DeviceServiceRemoveAssociations, "Remove Associations", associationInputs, nil),
+		this.newDeviceServiceDefinition(DeviceServiceCheckLifeline, "Check Lifeline", []models.ContentVariable{{Name: "repair", Type: models.Boolean}}, &models.ContentVariable{
+			Name: "result",
+			Type: models.Structure,
+			SubContentVariables: []models.ContentVariable{
+				{Name: "ok", Type: models.Boolean},
+				{Name: "groups", Type: models.List, SubContentVariables: []models.ContentVariable{{
+					Name: "*",
+					Type: models.Structure,
+					SubContentVariables: []models.ContentVariable{
+						{Name: "groupId", Type: models.Integer},
+						{Name: "endpoint", Type: models.Integer},
+						{Name: "ok", Type: models.Boolean},
+						{Name: "repaired", Type: models.Boolean},
+					},
+				}}},
+			},
+		}),
+		this.newDeviceServiceDefinition(DeviceServiceRename, "Rename", []models.ContentVariable{{Name: "name", Type: models.String}}, nil),
+		this.newDeviceServiceDefinition(DeviceServiceSetLocation, "Set Location", []models.ContentVariable{{Name: "location", Type: models.String}}, nil),
+		this.newDeviceEventServiceDefinition(DeviceServiceFirmwareUpdateStatus, "Firmware Update Status", models.ContentVariable{
+			Name: "status",
+			Type: models.Structure,
+			SubContentVariables: []models.ContentVariable{
+				{Name: "status", Type: models.String},
+				{Name: "file", Type: models.String},
+				{Name: "target", Type: models.Integer},
+				{Name: "sentFragments", Type: models.Integer},
+				{Name: "totalFragments", Type: models.Integer},
+				{Name: "progress", Type: models.Float},
+				{Name: "resultCode", Type: models.Integer},
+				{Name: "message", Type: models.String},
+				{Name: "time", Type: models.Integer},
+			},
+		}),
+	}
+}
+
+func (this *Connector) newDeviceEventServiceDefinition(localId string, name string, output models.ContentVariable) (result models.Service) {
+	result = this.newDeviceServiceDefinition(localId, name, nil, &output)
+	result.Interaction = models.EVENT
+	return result
+}
+
+func (this *Connector) newDeviceServiceDefinition(localId string, name string, inputs []models.ContentVariable, output *models.ContentVariable) (result models.Service) {
+	result = models.Service{
+		LocalId:     localId,
+		Name:        name,
+		Interaction: models.REQUEST,
+		ProtocolId:  this.config.CreateMissingDeviceTypesWithProtocol,
+		Inputs:      []models.Content{},
+		Outputs:     []models.Content{},
+	}
+	if len(inputs) > 0 {
+		result.Inputs = append(result.Inputs, models.Content{
+			ContentVariable: models.ContentVariable{
+				Name:                "data",
+				Type:                models.Structure,
+				SubContentVariables: inputs,
+			},
+			Serialization:     models.JSON,
+			ProtocolSegmentId: this.config.CreateMissingDeviceTypesWithProtocolSegment,
+		})
+	}
+	if output != nil {
+		result.Outputs = append(result.Outputs, models.Content{
+			ContentVariable:   *output,
+			Serialization:     models.JSON,
+			ProtocolSegmentId: this.config.CreateMissingDeviceTypesWithProtocolSegment,
+		})
+	}
+	return result
+}
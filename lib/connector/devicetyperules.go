@@ -0,0 +1,230 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+type deviceTypeRule struct {
+	manufacturer    pattern
+	productType     pattern
+	productId       pattern
+	firmwareVersion pattern
+	endpoint        pattern
+	endpointCount   pattern
+	commandClasses  []int64
+	deviceTypeId    string
+}
+
+// returns the rules sorted by priority
+func parseDeviceTypeRules(rules []configuration.DeviceTypeRule) (result []deviceTypeRule, err error) {
+	sorted := slices.Clone(rules)
+	slices.SortStableFunc(sorted, func(a, b configuration.DeviceTypeRule) int {
+		return b.Priority - a.Priority
+	})
+	for i, rule := range sorted {
+		endpoint := rule.Endpoint
+		if endpoint == "" {
+			endpoint = "0"
+		}
+		parsed := deviceTypeRule{commandClasses: rule.CommandClasses, deviceTypeId: rule.DeviceTypeId}
+		for _, p := range []struct {
+			target  *pattern
+			pattern string
+			compare func(a, b string) (int, error)
+		}{
+			{&parsed.manufacturer, rule.Manufacturer, compareNumbers},
+			{&parsed.productType, rule.ProductType, compareNumbers},
+			{&parsed.productId, rule.ProductId, compareNumbers},
+			{&parsed.firmwareVersion, rule.FirmwareVersion, compareVersions},
+			{&parsed.endpoint, endpoint, compareNumbers},
+			{&parsed.endpointCount, rule.EndpointCount, compareNumbers},
+		} {
+			*p.target, err = parsePattern(p.pattern, p.compare)
+			if err != nil {
+				return nil, fmt.Errorf("invalid device type rule %v (%v): %w", i, rule.DeviceTypeId, err)
+			}
+		}
+		result = append(result, parsed)
+	}
+	return result, nil
+}
+
+// rules are evaluated against the unfiltered node; command classes hidden by filter rules (e.g. 112 or 134) can be matched
+func (this *Connector) findDeviceTypeByRules(node model.DeviceInfo) (deviceTypeId string, ok bool) {
+	parts := strings.Split(node.GetCanonicalTypeMappingKey(), ".")
+	if len(parts) < 3 {
+		return "", false
+	}
+	node = getUnfilteredNode(node)
+	endpointCount := strconv.Itoa(len(getEndpoints(node)))
+	for _, rule := range this.deviceTypeRules {
+		if rule.manufacturer.matches(parts[0]) &&
+			rule.productType.matches(parts[1]) &&
+			rule.productId.matches(parts[2]) &&
+			rule.firmwareVersion.matches(node.FirmwareVersion) &&
+			rule.endpoint.matches(strconv.FormatInt(node.Endpoint, 10)) &&
+			rule.endpointCount.matches(endpointCount) &&
+			hasCommandClasses(node, rule.commandClasses) {
+			return rule.deviceTypeId, true
+		}
+	}
+	return "", false
+}
+
+func getUnfilteredNode(node model.DeviceInfo) model.DeviceInfo {
+	if len(node.FilteredValues) == 0 {
+		return node
+	}
+	values := maps.Clone(node.Values)
+	if values == nil {
+		values = map[string]model.Value{}
+	}
+	maps.Copy(values, node.FilteredValues)
+	node.Values = values
+	node.FilteredValues = nil
+	return node
+}
+
+func hasCommandClasses(node model.DeviceInfo, commandClasses []int64) bool {
+	for _, cc := range commandClasses {
+		found := false
+		for _, value := range node.Values {
+			if value.ClassId == cc {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// nil matches every value
+type pattern []patternAlternative
+
+type patternAlternative struct {
+	prefix  string //used if isGlob
+	lower   string
+	upper   string
+	isAny   bool
+	isGlob  bool
+	compare func(a, b string) (int, error)
+}
+
+func parsePattern(p string, compare func(a, b string) (int, error)) (result pattern, err error) {
+	p = strings.TrimSpace(p)
+	if p == "" {
+		return nil, nil
+	}
+	for _, alternative := range strings.Split(p, ",") {
+		alternative = strings.TrimSpace(alternative)
+		switch {
+		case alternative == "*":
+			result = append(result, patternAlternative{isAny: true})
+		case strings.HasSuffix(alternative, "*"):
+			result = append(result, patternAlternative{isGlob: true, prefix: strings.TrimSuffix(alternative, "*")})
+		default:
+			bounds := strings.SplitN(alternative, "-", 2)
+			lower, upper := bounds[0], bounds[0]
+			if len(bounds) == 2 {
+				upper = bounds[1]
+			}
+			if _, err = compare(lower, upper); err != nil {
+				return nil, fmt.Errorf("invalid pattern %q: %w", alternative, err)
+			}
+			result = append(result, patternAlternative{lower: lower, upper: upper, compare: compare})
+		}
+	}
+	return result, nil
+}
+
+func (this pattern) matches(value string) bool {
+	if this == nil {
+		return true
+	}
+	for _, alternative := range this {
+		if alternative.matches(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (this patternAlternative) matches(value string) bool {
+	if this.isAny {
+		return true
+	}
+	if this.isGlob {
+		return strings.HasPrefix(value, this.prefix)
+	}
+	lower, err := this.compare(this.lower, value)
+	if err != nil || lower > 0 {
+		return false
+	}
+	upper, err := this.compare(value, this.upper)
+	return err == nil && upper <= 0
+}
+
+// compares decimal or hex numbers
+func compareNumbers(a, b string) (int, error) {
+	x, err := model.ParseTypeMappingKeyPart(strings.TrimSpace(a))
+	if err != nil {
+		return 0, err
+	}
+	y, err := model.ParseTypeMappingKeyPart(strings.TrimSpace(b))
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case x < y:
+		return -1, nil
+	case x > y:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// compares dot separated versions part by part; missing parts are 0 (1.2 == 1.2.0)
+func compareVersions(a, b string) (int, error) {
+	x := strings.Split(strings.TrimSpace(a), ".")
+	y := strings.Split(strings.TrimSpace(b), ".")
+	for i := 0; i < max(len(x), len(y)); i++ {
+		xPart, yPart := "0", "0"
+		if i < len(x) {
+			xPart = x[i]
+		}
+		if i < len(y) {
+			yPart = y[i]
+		}
+		result, err := compareNumbers(xPart, yPart)
+		if err != nil || result != 0 {
+			return result, err
+		}
+	}
+	return 0, nil
+}
@@ -19,7 +19,6 @@ package connector
 import (
 	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo"
@@ -28,24 +27,7 @@ import (
 )
 
 func (this *Connector) provideDeviceTypeId(node model.DeviceInfo) (result string, err error) {
-	var known bool
-
-	if this.nodeDeviceTypeOverwrite != nil {
-		result, known = this.nodeDeviceTypeOverwrite[strconv.FormatInt(node.NodeId, 10)]
-		if known {
-			return result, nil
-		}
-	}
-
-	typeMappingKey := this.getTypeMappingKey(node)
-	result, known = this.deviceTypeMapping[typeMappingKey]
-	if known {
-		return result, nil
-	}
-
-	var usedFallback bool
-
-	result, usedFallback, err = this.devicerepo.FindDeviceTypeId(node)
+	result, usedFallback, err := this.findDeviceTypeId(node)
 	if errors.Is(err, model.NoMatchingDeviceTypeFound) {
 		this.config.GetLogger().Warn("unable to find matching device type", "error", err)
 		if !usedFallback {
@@ -64,8 +46,35 @@ func (this *Connector) provideDeviceTypeId(node model.DeviceInfo) (result string
 	return result, err
 }
 
+// findDeviceTypeId resolves the device type by node_device_type_overwrite, device_type_mapping, device_type_rules or the device type list;
+// device types are not created
+func (this *Connector) findDeviceTypeId(node model.DeviceInfo) (result string, usedFallback bool, err error) {
+	var known bool
+
+	deviceTypeMapping, nodeDeviceTypeOverwrite := this.getTypeMappings()
+	if nodeDeviceTypeOverwrite != nil {
+		result, known = nodeDeviceTypeOverwrite[node.GetRawDeviceId()]
+		if known {
+			return result, false, nil
+		}
+	}
+
+	typeMappingKey := this.getTypeMappingKey(node)
+	result, known = deviceTypeMapping[typeMappingKey]
+	if known {
+		return result, false, nil
+	}
+
+	result, known = this.findDeviceTypeByRules(node)
+	if known {
+		return result, false, nil
+	}
+
+	return this.devicerepo.FindDeviceTypeId(node)
+}
+
 func (this *Connector) createDeviceType(node model.DeviceInfo) (string, error) {
-	dt, _, err := this.devicerepo.CreateDeviceTypeWithDistinctAttributes(node.GetTypeMappingKey(), this.nodeToDeviceType(node), []string{devicerepo.AttributeUsedForZwave, devicerepo.AttributeZwaveTypeMappingKey})
+	dt, _, err := this.devicerepo.CreateDeviceTypeWithDistinctAttributes(node.GetCanonicalTypeMappingKey(), this.nodeToDeviceType(node), []string{devicerepo.AttributeUsedForZwave, devicerepo.AttributeZwaveTypeMappingKey})
 	return dt.Id, err
 }
 
@@ -76,7 +85,7 @@ func (this *Connector) nodeToDeviceType(node model.DeviceInfo) (result models.De
 		DeviceClassId: this.config.CreateMissingDeviceTypesWithDeviceClass,
 		Attributes: []models.Attribute{
 			{Key: devicerepo.AttributeUsedForZwave, Value: "true"},
-			{Key: devicerepo.AttributeZwaveTypeMappingKey, Value: node.GetTypeMappingKey()},
+			{Key: devicerepo.AttributeZwaveTypeMappingKey, Value: node.GetCanonicalTypeMappingKey()},
 		},
 		Services: []models.Service{
 			{
@@ -105,6 +114,13 @@ func (this *Connector) nodeToDeviceType(node model.DeviceInfo) (result models.De
 			},
 		},
 	}
+	if node.Endpoint > 0 {
+		//statistics and connector-level services are only available for the node device
+		result.Name = fmt.Sprintf("UNFINISHED zwavejs2mqtt %v %v endpoint %v", node.Manufacturer, node.Product, node.Endpoint)
+		result.Services = []models.Service{}
+	} else {
+		result.Services = append(result.Services, this.getDeviceServiceDefinitions()...)
+	}
 	for _, value := range node.Values {
 		var valueType models.Type
 		switch strings.ToLower(value.Type) {
@@ -130,6 +146,7 @@ func (this *Connector) nodeToDeviceType(node model.DeviceInfo) (result models.De
 			continue
 		}
 		if !value.WriteOnly {
+			semantics, _ := this.getValueSemantics(value, true)
 			result.Services = append(result.Services, models.Service{
 				LocalId:     value.GetServiceId(true),
 				Name:        getServiceName(value, true),
@@ -143,8 +160,11 @@ func (this *Connector) nodeToDeviceType(node model.DeviceInfo) (result models.De
 						Type:   models.Structure,
 						SubContentVariables: []models.ContentVariable{
 							{
-								Name: "value",
-								Type: valueType,
+								Name:             "value",
+								Type:             valueType,
+								FunctionId:       semantics.FunctionId,
+								AspectId:         semantics.AspectId,
+								CharacteristicId: semantics.CharacteristicId,
 							},
 							{
 								Name:             "lastUpdate",
@@ -170,6 +190,7 @@ func (this *Connector) nodeToDeviceType(node model.DeviceInfo) (result models.De
 			})
 		}
 		if !value.ReadOnly {
+			semantics, _ := this.getValueSemantics(value, false)
 			result.Services = append(result.Services, models.Service{
 				LocalId:     value.GetServiceId(false),
 				Name:        getServiceName(value, false),
@@ -178,8 +199,11 @@ func (this *Connector) nodeToDeviceType(node model.DeviceInfo) (result models.De
 				ProtocolId:  this.config.CreateMissingDeviceTypesWithProtocol,
 				Inputs: []models.Content{{
 					ContentVariable: models.ContentVariable{
-						Name: "value",
-						Type: valueType,
+						Name:             "value",
+						Type:             valueType,
+						FunctionId:       semantics.FunctionId,
+						AspectId:         semantics.AspectId,
+						CharacteristicId: semantics.CharacteristicId,
 					},
 					Serialization:     models.JSON,
 					ProtocolSegmentId: this.config.CreateMissingDeviceTypesWithProtocolSegment,
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+	"github.com/SENERGY-Platform/models/go/models"
+)
+
+const unfinishedDeviceTypeNamePrefix = "UNFINISHED "
+
+// reports services of the node missing in the device type; device types created by the connector are updated;
+// runs in the background once per device type and set of value services
+func (this *Connector) syncDeviceType(deviceId string, node model.DeviceInfo, deviceTypeId string) {
+	if len(node.Values) == 0 {
+		return //partial device info
+	}
+	key := getDeviceTypeSyncKey(deviceTypeId, node)
+	if !this.startDeviceTypeSync(key) {
+		return
+	}
+	go func() {
+		missing, updated, err := this.updateDeviceTypeServices(node, deviceTypeId)
+		if err != nil {
+			this.config.GetLogger().Error("unable to sync device type", "device", deviceId, "deviceType", deviceTypeId, "error", err)
+			this.resetDeviceTypeSync(key)
+			return
+		}
+		if len(missing) == 0 || updated {
+			return
+		}
+		if !this.markDeviceTypeGapReported(deviceTypeId, missing) {
+			return
+		}
+		this.config.GetLogger().Warn("device type misses services of node", "device", deviceId, "deviceType", deviceTypeId, "mappingKey", node.GetTypeMappingKey(), "services", missing)
+		this.mgwClient.SendDeviceError(deviceId, "device type "+deviceTypeId+" misses services: "+strings.Join(missing, ", "))
+	}()
+}
+
+func getDeviceTypeSyncKey(deviceTypeId string, node model.DeviceInfo) string {
+	services := []string{}
+	for _, value := range node.Values {
+		services = append(services, value.GetServiceId(true))
+	}
+	slices.Sort(services)
+	return deviceTypeId + "|" + strings.Join(slices.Compact(services), ",")
+}
+
+// returns false if the sync is running or has been completed
+func (this *Connector) startDeviceTypeSync(key string) bool {
+	this.deviceTypeSyncsMux.Lock()
+	defer this.deviceTypeSyncsMux.Unlock()
+	if this.deviceTypeSyncs[key] {
+		return false
+	}
+	this.deviceTypeSyncs[key] = true
+	return true
+}
+
+// failed syncs are retried with the next device info
+func (this *Connector) resetDeviceTypeSync(key string) {
+	this.deviceTypeSyncsMux.Lock()
+	defer this.deviceTypeSyncsMux.Unlock()
+	delete(this.deviceTypeSyncs, key)
+}
+
+// returns the missing service ids and if the device type has been updated
+func (this *Connector) updateDeviceTypeServices(node model.DeviceInfo, deviceTypeId string) (missing []string, updated bool, err error) {
+	dt, err := this.devicerepo.GetDeviceType(deviceTypeId)
+	if err != nil {
+		return nil, false, err
+	}
+	missingServices := getMissingServices(dt, this.nodeToDeviceType(node), node)
+	if len(missingServices) == 0 {
+		return nil, false, nil
+	}
+	for _, service := range missingServices {
+		missing = append(missing, service.LocalId)
+	}
+	if !strings.HasPrefix(dt.Name, unfinishedDeviceTypeNamePrefix) {
+		return missing, false, nil
+	}
+	dt.Services = append(slices.Clone(dt.Services), missingServices...)
+	_, _, err = this.devicerepo.UpdateDeviceType(dt, []string{devicerepo.AttributeUsedForZwave, devicerepo.AttributeZwaveTypeMappingKey})
+	if err != nil {
+		return missing, false, err
+	}
+	this.config.GetLogger().Info("added missing services to device type", "deviceType", deviceTypeId, "services", missing)
+	return missing, true, nil
+}
+
+// only value services are compared; connector services may be omitted intentionally by curated device types
+func getMissingServices(dt models.DeviceType, expected models.DeviceType, node model.DeviceInfo) (result []models.Service) {
+	known := map[string]bool{}
+	for _, service := range dt.Services {
+		known[service.LocalId] = true
+	}
+	valueServices := map[string]bool{}
+	for _, value := range node.Values {
+		valueServices[value.GetServiceId(true)] = true
+		valueServices[value.GetServiceId(false)] = true
+	}
+	for _, service := range expected.Services {
+		if valueServices[service.LocalId] && !known[service.LocalId] {
+			result = append(result, service)
+		}
+	}
+	return result
+}
+
+// returns true if the missing services have not been reported for the device type
+func (this *Connector) markDeviceTypeGapReported(deviceTypeId string, missing []string) bool {
+	this.reportedDeviceTypeGapsMux.Lock()
+	defer this.reportedDeviceTypeGapsMux.Unlock()
+	key := strings.Join(missing, ",")
+	if this.reportedDeviceTypeGaps[deviceTypeId] == key {
+		return false
+	}
+	this.reportedDeviceTypeGaps[deviceTypeId] = key
+	return true
+}
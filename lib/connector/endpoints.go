@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+// returns the node itself or, if configured in split_endpoints, the root device and one device per multi-channel endpoint;
+// returns ErrDeviceTypeListPending if the split decision needs the device type of the node and the list is not ready
+func (this *Connector) splitNodeByEndpoints(node model.DeviceInfo) (result []model.DeviceInfo, err error) {
+	endpoints := getEndpoints(node)
+	if len(endpoints) == 0 {
+		return []model.DeviceInfo{node}, nil
+	}
+	split, err := this.endpointsShouldBeSplit(node)
+	if err != nil || !split {
+		return []model.DeviceInfo{node}, err
+	}
+	root := node
+	root.Values = filterValuesByEndpoint(node.Values, 0)
+	root.FilteredValues = filterValuesByEndpoint(node.FilteredValues, 0)
+	result = append(result, root)
+	for _, endpoint := range endpoints {
+		child := node
+		child.Endpoint = endpoint
+		child.Values = filterValuesByEndpoint(node.Values, endpoint)
+		child.FilteredValues = filterValuesByEndpoint(node.FilteredValues, endpoint)
+		child.Statistics = model.Statistics{}
+		if child.Name != "" {
+			child.Name = child.Name + " " + strconv.FormatInt(endpoint, 10)
+		}
+		result = append(result, child)
+	}
+	return result, nil
+}
+
+// the device type of the whole node is only looked up; creating it would register an unused device type for split nodes
+func (this *Connector) endpointsShouldBeSplit(node model.DeviceInfo) (bool, error) {
+	if len(this.config.SplitEndpoints) == 0 {
+		return false, nil
+	}
+	if slices.ContainsFunc(this.config.SplitEndpoints, func(key string) bool { return model.TypeMappingKeysMatch(key, node.GetTypeMappingKey()) }) {
+		return true, nil
+	}
+	deviceTypeId, _, err := this.findDeviceTypeId(node)
+	if errors.Is(err, devicerepo.ErrDeviceTypeListPending) {
+		return false, err
+	}
+	if err != nil {
+		return false, nil
+	}
+	return slices.Contains(this.config.SplitEndpoints, deviceTypeId), nil
+}
+
+// endpoints reported by the controller and endpoints found in values; 0 (the root device) is excluded
+func getEndpoints(node model.DeviceInfo) (result []int64) {
+	for _, endpoint := range node.Endpoints {
+		if endpoint > 0 && !slices.Contains(result, endpoint) {
+			result = append(result, endpoint)
+		}
+	}
+	for _, value := range node.Values {
+		if value.Instance > 0 && !slices.Contains(result, value.Instance) {
+			result = append(result, value.Instance)
+		}
+	}
+	slices.Sort(result)
+	return result
+}
+
+func filterValuesByEndpoint(values map[string]model.Value, endpoint int64) (result map[string]model.Value) {
+	result = map[string]model.Value{}
+	for key, value := range values {
+		if value.Instance == endpoint {
+			result[key] = value
+		}
+	}
+	return result
+}
+
+// returns the endpoint device id if the value belongs to a registered endpoint device, else the node device id
+func (this *Connector) getDeviceIdForValue(controller *Controller, nodeValue model.Value) string {
+	deviceId := controller.nodeIdToDeviceId(nodeValue.NodeId)
+	if nodeValue.Instance > 0 {
+		endpointDeviceId := deviceId + "." + strconv.FormatInt(nodeValue.Instance, 10)
+		if _, ok := this.deviceRegisterGet(endpointDeviceId); ok {
+			return endpointDeviceId
+		}
+	}
+	return deviceId
+}
+
+// returns the node device id and the ids of its registered endpoint devices
+func (this *Connector) getDeviceIdsOfNode(controller *Controller, nodeId int64) (result []string) {
+	deviceId := controller.nodeIdToDeviceId(nodeId)
+	result = []string{deviceId}
+	for _, id := range this.deviceRegisterGetIds() {
+		if strings.HasPrefix(id, deviceId+".") {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// expects ids from mgw (with prefixes); returns the node id part of <prefix>:<nodeId> or <prefix>:<nodeId>.<endpoint>
+func (this *Controller) deviceIdToRawNodeId(deviceId string) string {
+	rawId := this.removeDeviceIdPrefix(deviceId)
+	nodeId, _, _ := strings.Cut(rawId, ".")
+	return nodeId
+}
+
+// expects ids from mgw (with prefixes); returns the endpoint of <prefix>:<nodeId>.<endpoint> or 0 for node devices
+func (this *Controller) deviceIdToEndpoint(deviceId string) int64 {
+	_, endpoint, found := strings.Cut(this.removeDeviceIdPrefix(deviceId), ".")
+	if !found {
+		return 0
+	}
+	result, err := strconv.ParseInt(endpoint, 10, 64)
+	if err != nil {
+		return 0
+	}
+	return result
+}
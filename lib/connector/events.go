@@ -22,13 +22,22 @@ import (
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
 )
 
-func (this *Connector) ValueEventListener(nodeValue model.Value) {
-	deviceId, serviceId, value, err := this.parseNodeValueAsMgwEvent(nodeValue)
+func (this *Connector) ValueEventListener(controller *Controller, nodeValue model.Value) {
+	deviceId, serviceId, value, err := this.parseNodeValueAsMgwEvent(controller, nodeValue)
 	if err != nil {
 		this.config.GetLogger().Error("unable to create device-id and service-id for node-value", "error", err)
 		this.mgwClient.SendClientError("unable to create device-id and service-id for node-value: " + err.Error())
 		return
 	}
+	this.nodeStoreUpdateValue(controller, nodeValue)
+	node, ok := this.nodeStoreGet(controller, controller.nodeIdToDeviceId(nodeValue.NodeId))
+	if !ok {
+		node = model.DeviceInfo{NodeId: nodeValue.NodeId}
+	}
+	if !this.valueIsExposed(node, nodeValue) {
+		this.config.GetLogger().Debug("ignore value because of filters", "device", deviceId, "service", serviceId)
+		return
+	}
 	if this.eventShouldBeSend(deviceId) {
 		this.saveValue(deviceId, serviceId, value)
 		err = this.mgwClient.MarshalAndSendEvent(deviceId, serviceId, value)
@@ -37,6 +46,7 @@ func (this *Connector) ValueEventListener(nodeValue model.Value) {
 			this.mgwClient.SendClientError("unable to send event: " + err.Error())
 			return
 		}
+		this.sendLegacyEvent(controller, deviceId, serviceId, value)
 	} else {
 		this.config.GetLogger().Debug("ignore event for device because the device is not registered", "device", deviceId)
 	}
@@ -50,9 +60,9 @@ func (this *Connector) eventShouldBeSend(id string) bool {
 	return ok
 }
 
-func (this *Connector) sendStatistics(node model.DeviceInfo) {
+func (this *Connector) sendStatistics(controller *Controller, node model.DeviceInfo) {
 	rawDeviceId := strconv.FormatInt(node.NodeId, 10)
-	deviceId := this.addDeviceIdPrefix(rawDeviceId)
+	deviceId := controller.addDeviceIdPrefix(rawDeviceId)
 	err := this.mgwClient.MarshalAndSendEvent(deviceId, "statistics", node.Statistics)
 	if err != nil {
 		this.config.GetLogger().Error("unable to send event", "device", deviceId, "service", "statistics", "error", err)
@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+// configuration, diagnostic and raw basic command classes are only exposed if an allow rule names them (by command class or property)
+var HiddenCommandClasses = []int64{
+	32,  //Basic
+	89,  //Association Group Information
+	90,  //Device Reset Locally
+	94,  //Z-Wave Plus Info
+	112, //Configuration
+	114, //Manufacturer Specific
+	115, //Powerlevel
+	119, //Node Naming and Location
+	122, //Firmware Update Meta Data
+	132, //Wake Up
+	133, //Association
+	134, //Version
+	142, //Multi Channel Association
+}
+
+// nodes are exposed if no deny node rule matches and, if allow node rules exist, one of them matches
+func (this *Connector) nodeIsExposed(node model.DeviceInfo) bool {
+	filters := this.config.Filters
+	hasAllowRules := false
+	for _, rule := range filters.Deny {
+		if isNodeRule(rule) && ruleMatchesNode(rule, node) {
+			return false
+		}
+	}
+	for _, rule := range filters.Allow {
+		if !isNodeRule(rule) {
+			continue
+		}
+		if ruleMatchesNode(rule, node) {
+			return true
+		}
+		hasAllowRules = true
+	}
+	return !hasAllowRules
+}
+
+// values are exposed if their node is exposed, no deny rule matches and their command class is not hidden or explicitly allowed
+func (this *Connector) valueIsExposed(node model.DeviceInfo, value model.Value) bool {
+	if !this.nodeIsExposed(node) {
+		return false
+	}
+	for _, rule := range this.config.Filters.Deny {
+		if !isNodeRule(rule) && ruleMatchesValue(rule, node, value) {
+			return false
+		}
+	}
+	if !slices.Contains(HiddenCommandClasses, value.ClassId) {
+		return true
+	}
+	for _, rule := range this.config.Filters.Allow {
+		if !isNodeRule(rule) && ruleMatchesValue(rule, node, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// returns a copy of the node with the exposed values; hidden values are moved to FilteredValues
+func (this *Connector) filterNodeValues(node model.DeviceInfo) model.DeviceInfo {
+	values := map[string]model.Value{}
+	filtered := map[string]model.Value{}
+	for key, value := range node.Values {
+		if this.valueIsExposed(node, value) {
+			values[key] = value
+		} else {
+			filtered[key] = value
+		}
+	}
+	node.Values = values
+	node.FilteredValues = filtered
+	return node
+}
+
+func isNodeRule(rule configuration.FilterRule) bool {
+	return len(rule.CommandClasses) == 0 && len(rule.Properties) == 0
+}
+
+func ruleMatchesNode(rule configuration.FilterRule, node model.DeviceInfo) bool {
+	if len(rule.NodeIds) > 0 && !slices.Contains(rule.NodeIds, node.NodeId) {
+		return false
+	}
+	if len(rule.MappingKeys) > 0 && !slices.ContainsFunc(rule.MappingKeys, func(key string) bool { return model.TypeMappingKeysMatch(key, node.GetTypeMappingKey()) }) {
+		return false
+	}
+	return true
+}
+
+func ruleMatchesValue(rule configuration.FilterRule, node model.DeviceInfo, value model.Value) bool {
+	if !ruleMatchesNode(rule, node) {
+		return false
+	}
+	if len(rule.CommandClasses) > 0 && !slices.Contains(rule.CommandClasses, value.ClassId) {
+		return false
+	}
+	if len(rule.Properties) > 0 && (value.Property == nil || !slices.Contains(rule.Properties, fmt.Sprint(value.Property))) {
+		return false
+	}
+	return true
+}
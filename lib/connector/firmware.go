@@ -0,0 +1,305 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const DefaultFirmwareUpdateTimeout = time.Hour
+
+const FirmwareDownloadTimeout = 5 * time.Minute
+
+const DefaultFirmwareMaxSize = 16 << 20
+
+// zwave-js FirmwareUpdateStatus values >= 253 mean success
+const FirmwareUpdateStatusOk = 253
+
+// firmware update event statuses
+const (
+	FirmwareUpdateStarted  = "started"
+	FirmwareUpdateProgress = "progress"
+	FirmwareUpdateFinished = "finished"
+	FirmwareUpdateFailed   = "failed"
+	FirmwareUpdateAborted  = "aborted"
+)
+
+type FirmwareUpdateInput struct {
+	File   string `json:"file"`   //http(s) url or path in firmware_directory
+	Target int64  `json:"target"` //firmware target (chip), 0 for the main firmware
+}
+
+type FirmwareUpdateEvent struct {
+	Status         string  `json:"status"`
+	File           string  `json:"file"`
+	Target         int64   `json:"target"`
+	SentFragments  int64   `json:"sentFragments"`
+	TotalFragments int64   `json:"totalFragments"`
+	Progress       float64 `json:"progress"` //percent
+	ResultCode     *int64  `json:"resultCode,omitempty"`
+	Message        string  `json:"message,omitempty"`
+	Time           int64   `json:"time"`
+}
+
+type firmwareUpdate struct {
+	nodeId int64
+	file   string
+	target int64
+	timer  *time.Timer
+}
+
+func (this *Connector) updateFirmwareCommand(controller *Controller, _ string, nodeId int64, data string) (result interface{}, err error) {
+	input := FirmwareUpdateInput{}
+	err = json.Unmarshal([]byte(data), &input)
+	if err != nil {
+		return nil, fmt.Errorf("unable to unmarshal command data: %w", err)
+	}
+	if input.File == "" {
+		return nil, errors.New("missing firmware file")
+	}
+	err = this.reserveFirmwareUpdate(controller, nodeId, input)
+	if err != nil {
+		return nil, err
+	}
+	fileName, content, err := this.loadFirmwareFile(input.File)
+	if err != nil {
+		this.releaseFirmwareUpdate(controller, nodeId)
+		return nil, err
+	}
+	result, err = controller.client.BeginFirmwareUpdate(nodeId, fileName, content, input.Target)
+	if err != nil {
+		this.releaseFirmwareUpdate(controller, nodeId)
+		return nil, err
+	}
+	this.sendFirmwareUpdateEvent(controller, nodeId, FirmwareUpdateEvent{Status: FirmwareUpdateStarted, File: input.File, Target: input.Target})
+	return result, nil
+}
+
+func (this *Connector) abortFirmwareUpdateCommand(controller *Controller, _ string, nodeId int64, _ string) (result interface{}, err error) {
+	result, err = controller.client.AbortFirmwareUpdate(nodeId)
+	if err != nil {
+		return nil, err
+	}
+	if update, ok := this.releaseFirmwareUpdate(controller, nodeId); ok {
+		this.sendFirmwareUpdateEvent(controller, nodeId, FirmwareUpdateEvent{Status: FirmwareUpdateAborted, File: update.file, Target: update.target})
+	}
+	return result, nil
+}
+
+// refuses concurrent updates on one controller; the reservation is released by the finish event, an abort or the timeout
+func (this *Connector) reserveFirmwareUpdate(controller *Controller, nodeId int64, input FirmwareUpdateInput) error {
+	controller.nodeTaskMux.Lock()
+	defer controller.nodeTaskMux.Unlock()
+	if controller.firmwareUpdate != nil {
+		return fmt.Errorf("firmware update of node %v is already running on this controller", controller.firmwareUpdate.nodeId)
+	}
+	timeout := this.config.FirmwareUpdateTimeout.GetDuration()
+	if timeout == 0 {
+		timeout = DefaultFirmwareUpdateTimeout
+	}
+	controller.firmwareUpdate = &firmwareUpdate{
+		nodeId: nodeId,
+		file:   input.File,
+		target: input.Target,
+		timer: time.AfterFunc(timeout, func() {
+			if update, ok := this.releaseFirmwareUpdate(controller, nodeId); ok {
+				this.config.GetLogger().Warn("firmware update timeout", "controller", controller.deviceIdPrefix, "node", nodeId)
+				this.sendFirmwareUpdateEvent(controller, nodeId, FirmwareUpdateEvent{Status: FirmwareUpdateFailed, File: update.file, Target: update.target, Message: "timeout"})
+			}
+		}),
+	}
+	return nil
+}
+
+func (this *Connector) releaseFirmwareUpdate(controller *Controller, nodeId int64) (update firmwareUpdate, ok bool) {
+	controller.nodeTaskMux.Lock()
+	defer controller.nodeTaskMux.Unlock()
+	if controller.firmwareUpdate == nil || controller.firmwareUpdate.nodeId != nodeId {
+		return update, false
+	}
+	update = *controller.firmwareUpdate
+	update.timer.Stop()
+	controller.firmwareUpdate = nil
+	return update, true
+}
+
+func (this *Connector) getRunningFirmwareUpdate(controller *Controller, nodeId int64) (update firmwareUpdate, ok bool) {
+	controller.nodeTaskMux.Lock()
+	defer controller.nodeTaskMux.Unlock()
+	if controller.firmwareUpdate == nil || controller.firmwareUpdate.nodeId != nodeId {
+		return update, false
+	}
+	return *controller.firmwareUpdate, true
+}
+
+/*
+zwavejs2mqtt sends
+
+	firmware update progress: [sentFragments, totalFragments] or [{"sentFragments": 10, "totalFragments": 100, "progress": 10}]
+	firmware update finished: [status, waitTime] or [{"status": 255, "success": true, "waitTime": 10}]
+*/
+func (this *Connector) handleFirmwareUpdateEvent(controller *Controller, event string, nodeId int64, data []interface{}) {
+	switch event {
+	case "firmware update progress":
+		update, ok := this.getRunningFirmwareUpdate(controller, nodeId)
+		if !ok {
+			return
+		}
+		msg := FirmwareUpdateEvent{Status: FirmwareUpdateProgress, File: update.file, Target: update.target}
+		if len(data) >= 2 {
+			msg.SentFragments, _ = toInt64(data[0])
+			msg.TotalFragments, _ = toInt64(data[1])
+		} else if len(data) == 1 {
+			progress, _ := data[0].(map[string]interface{})
+			msg.SentFragments, _ = toInt64(progress["sentFragments"])
+			msg.TotalFragments, _ = toInt64(progress["totalFragments"])
+		}
+		if msg.TotalFragments > 0 {
+			msg.Progress = float64(msg.SentFragments) * 100 / float64(msg.TotalFragments)
+		}
+		this.sendFirmwareUpdateEvent(controller, nodeId, msg)
+	case "firmware update finished":
+		update, ok := this.releaseFirmwareUpdate(controller, nodeId)
+		if !ok {
+			return
+		}
+		var status, waitTime int64
+		if len(data) > 0 {
+			if result, isMap := data[0].(map[string]interface{}); isMap {
+				status, _ = toInt64(result["status"])
+				waitTime, _ = toInt64(result["waitTime"])
+			} else {
+				status, _ = toInt64(data[0])
+			}
+		}
+		if len(data) > 1 {
+			waitTime, _ = toInt64(data[1])
+		}
+		msg := FirmwareUpdateEvent{Status: FirmwareUpdateFinished, File: update.file, Target: update.target, ResultCode: &status, Progress: 100}
+		if status < FirmwareUpdateStatusOk {
+			msg.Status = FirmwareUpdateFailed
+			msg.Progress = 0
+		}
+		this.sendFirmwareUpdateEvent(controller, nodeId, msg)
+		if msg.Status == FirmwareUpdateFinished {
+			go func() {
+				//the device may need some time to activate the new firmware
+				time.Sleep(time.Duration(waitTime) * time.Second)
+				_, err := this.reInterview(controller, nodeId)
+				if err != nil {
+					this.config.GetLogger().Error("unable to re-interview node after firmware update", "controller", controller.deviceIdPrefix, "node", nodeId, "error", err)
+					this.mgwClient.SendClientError("unable to re-interview node after firmware update: " + err.Error())
+				}
+			}()
+		}
+	}
+}
+
+func (this *Connector) sendFirmwareUpdateEvent(controller *Controller, nodeId int64, event FirmwareUpdateEvent) {
+	deviceId := controller.nodeIdToDeviceId(nodeId)
+	if !this.eventShouldBeSend(deviceId) {
+		return
+	}
+	event.Time = time.Now().UnixMilli()
+	err := this.mgwClient.MarshalAndSendEvent(deviceId, DeviceServiceFirmwareUpdateStatus, event)
+	if err != nil {
+		this.config.GetLogger().Error("unable to send event", "device", deviceId, "service", DeviceServiceFirmwareUpdateStatus, "error", err)
+		this.mgwClient.SendClientError("unable to send event: " + err.Error())
+	}
+}
+
+// file may be a http(s) url (e.g. served by the mgw) or a path in firmware_directory
+func (this *Connector) loadFirmwareFile(file string) (fileName string, content []byte, err error) {
+	maxSize := this.config.FirmwareMaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultFirmwareMaxSize
+	}
+	if strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://") {
+		fileUrl, err := url.Parse(file)
+		if err != nil {
+			return fileName, content, fmt.Errorf("invalid firmware url: %w", err)
+		}
+		client := http.Client{Timeout: FirmwareDownloadTimeout}
+		resp, err := client.Get(file)
+		if err != nil {
+			return fileName, content, fmt.Errorf("unable to download firmware: %w", err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fileName, content, fmt.Errorf("unable to download firmware: %v", resp.Status)
+		}
+		content, err = readFirmware(resp.Body, maxSize)
+		if err != nil {
+			return fileName, content, fmt.Errorf("unable to download firmware: %w", err)
+		}
+		return path.Base(fileUrl.Path), content, nil
+	}
+	if this.config.FirmwareDirectory == "" {
+		return fileName, content, errors.New("local firmware files are disabled; set firmware_directory or use a http(s) url")
+	}
+	f, err := openInDirectory(this.config.FirmwareDirectory, file)
+	if err != nil {
+		return fileName, content, fmt.Errorf("unable to read firmware file: %w", err)
+	}
+	defer f.Close()
+	content, err = readFirmware(f, maxSize)
+	if err != nil {
+		return fileName, content, fmt.Errorf("unable to read firmware file: %w", err)
+	}
+	return filepath.Base(file), content, nil
+}
+
+// openInDirectory opens relative paths and absolute paths within dir; paths (and symlinks) leading out of dir are refused
+func openInDirectory(dir string, file string) (*os.File, error) {
+	if filepath.IsAbs(file) {
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		file, err = filepath.Rel(absDir, file)
+		if err != nil {
+			return nil, err
+		}
+	}
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	return root.Open(file)
+}
+
+func readFirmware(r io.Reader, maxSize int64) (content []byte, err error) {
+	content, err = io.ReadAll(io.LimitReader(r, maxSize+1))
+	if err != nil {
+		return content, err
+	}
+	if int64(len(content)) > maxSize {
+		return nil, fmt.Errorf("firmware exceeds firmware_max_size (%v bytes)", maxSize)
+	}
+	return content, nil
+}
@@ -0,0 +1,85 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+	"github.com/SENERGY-Platform/models/go/models"
+)
+
+// GenerateDeviceTypes returns the device types create_missing_device_types would create for the nodes, by mapping key,
+// without device repository; filters and split_endpoints (by mapping key or device_type_mapping) are applied.
+// Nodes sharing a mapping key are merged.
+func GenerateDeviceTypes(config configuration.Config, nodes []model.DeviceInfo) map[string]models.DeviceType {
+	config.CreateMissingDeviceTypes = false
+	c := &Connector{
+		config:                  config,
+		deviceTypeMapping:       canonicalizeMappingKeys(config.DeviceTypeMapping, config.GetLogger()),
+		nodeDeviceTypeOverwrite: config.NodeDeviceTypeOverwrite,
+		devicerepo:              offlineDeviceRepo{},
+	}
+	result := map[string]models.DeviceType{}
+	for _, node := range nodes {
+		if !c.nodeIsExposed(node) {
+			continue
+		}
+		devices, _ := c.splitNodeByEndpoints(c.filterNodeValues(node)) //the offline repository is never pending
+		for _, device := range devices {
+			key := device.GetCanonicalTypeMappingKey()
+			dt := c.nodeToDeviceType(device)
+			if existing, ok := result[key]; ok {
+				existing.Services = append(existing.Services, getMissingServices(existing, dt, device)...)
+				dt = existing
+			}
+			result[key] = dt
+		}
+	}
+	return result
+}
+
+var errNoDeviceRepository = errors.New("no device repository available in offline mode")
+
+type offlineDeviceRepo struct{}
+
+func (offlineDeviceRepo) FindDeviceTypeId(device model.DeviceInfo) (dtId string, usedFallback bool, err error) {
+	return "", true, fmt.Errorf("%w: mapping-key=%v", model.NoMatchingDeviceTypeFound, device.GetTypeMappingKey())
+}
+
+func (offlineDeviceRepo) CreateDeviceTypeWithDistinctAttributes(_ string, _ models.DeviceType, _ []string) (models.DeviceType, int, error) {
+	return models.DeviceType{}, 500, errNoDeviceRepository
+}
+
+func (offlineDeviceRepo) GetDeviceType(_ string) (models.DeviceType, error) {
+	return models.DeviceType{}, errNoDeviceRepository
+}
+
+func (offlineDeviceRepo) UpdateDeviceType(_ models.DeviceType, _ []string) (models.DeviceType, int, error) {
+	return models.DeviceType{}, 500, errNoDeviceRepository
+}
+
+func (offlineDeviceRepo) GetSemanticIds() (map[string]string, error) {
+	return nil, errNoDeviceRepository
+}
+
+func (offlineDeviceRepo) CircuitState() devicerepo.CircuitState {
+	return devicerepo.CircuitOpen //no device repository available
+}
@@ -0,0 +1,297 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/mgw"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+const DeviceGroupLocalIdPrefix = "group:"
+
+// controller services to manage device groups
+const (
+	ControllerServiceCreateGroup = "createGroup"
+	ControllerServiceDeleteGroup = "deleteGroup"
+)
+
+func (this *Controller) getGroupDeviceId(name string) string {
+	return this.addDeviceIdPrefix(DeviceGroupLocalIdPrefix + name)
+}
+
+func (this *Controller) isGroupDeviceId(deviceId string) bool {
+	return strings.HasPrefix(this.removeDeviceIdPrefix(deviceId), DeviceGroupLocalIdPrefix)
+}
+
+// assigns the configured groups and the groups created by command (stored in the fallback file) to their controllers
+func (this *Connector) initDeviceGroups() error {
+	for _, group := range this.config.DeviceGroups {
+		_, err := this.addDeviceGroup(group)
+		if err != nil {
+			return err
+		}
+	}
+	stored, _ := getStored[[]configuration.DeviceGroupConfig](this, StoreKeyDeviceGroups)
+	for _, group := range stored {
+		_, err := this.addDeviceGroup(group)
+		if err != nil {
+			this.config.GetLogger().Error("unable to restore device group created by command", "group", group.Name, "error", err)
+		}
+	}
+	return nil
+}
+
+// replaces (or with remove deletes) the group in the list of groups created by command
+func (this *Connector) updateStoredDeviceGroup(group configuration.DeviceGroupConfig, remove bool) {
+	this.storedDeviceGroupsMux.Lock()
+	defer this.storedDeviceGroupsMux.Unlock()
+	stored, _ := getStored[[]configuration.DeviceGroupConfig](this, StoreKeyDeviceGroups)
+	stored = slices.DeleteFunc(stored, func(existing configuration.DeviceGroupConfig) bool {
+		return existing.Name == group.Name && existing.DeviceIdPrefix == group.DeviceIdPrefix
+	})
+	if !remove {
+		stored = append(stored, group)
+	}
+	this.setStored(StoreKeyDeviceGroups, stored)
+}
+
+func (this *Connector) addDeviceGroup(group configuration.DeviceGroupConfig) (controller *Controller, err error) {
+	if group.Name == "" {
+		return nil, errors.New("missing device group name")
+	}
+	if !group.Broadcast && len(group.NodeIds) == 0 {
+		return nil, fmt.Errorf("device group %v has no nodes", group.Name)
+	}
+	if group.Broadcast && group.DeviceTypeId == "" {
+		return nil, fmt.Errorf("broadcast device group %v has no device_type_id", group.Name) //no members to take the device type from
+	}
+	if len(this.controllers) == 0 {
+		return nil, errors.New("no controller for device group " + group.Name)
+	}
+	controller = this.controllers[0]
+	if group.DeviceIdPrefix != "" {
+		controller = nil
+		for _, c := range this.controllers {
+			if c.deviceIdPrefix == group.DeviceIdPrefix {
+				controller = c
+			}
+		}
+		if controller == nil {
+			return nil, fmt.Errorf("unknown device_id_prefix %v in device group %v", group.DeviceIdPrefix, group.Name)
+		}
+	}
+	group.DeviceIdPrefix = controller.deviceIdPrefix
+	id := controller.getGroupDeviceId(group.Name)
+	this.deviceGroupsMux.Lock()
+	defer this.deviceGroupsMux.Unlock()
+	if _, exists := this.deviceGroups[id]; exists {
+		return nil, fmt.Errorf("device group %v already exists", group.Name)
+	}
+	this.deviceGroups[id] = group
+	return controller, nil
+}
+
+func (this *Connector) getDeviceGroup(deviceId string) (group configuration.DeviceGroupConfig, ok bool) {
+	this.deviceGroupsMux.Lock()
+	defer this.deviceGroupsMux.Unlock()
+	group, ok = this.deviceGroups[deviceId]
+	return group, ok
+}
+
+func (this *Connector) getDeviceGroupsOfController(controller *Controller) (result []configuration.DeviceGroupConfig) {
+	this.deviceGroupsMux.Lock()
+	defer this.deviceGroupsMux.Unlock()
+	for _, group := range this.deviceGroups {
+		if group.DeviceIdPrefix == controller.deviceIdPrefix {
+			result = append(result, group)
+		}
+	}
+	return result
+}
+
+// registers the groups of the controller; groups without device type are skipped until a member is registered
+func (this *Connector) registerDeviceGroups(controller *Controller) (result map[string]mgw.DeviceInfo) {
+	result = map[string]mgw.DeviceInfo{}
+	for _, group := range this.getDeviceGroupsOfController(controller) {
+		id, info, err := this.registerDeviceGroup(controller, group)
+		if err != nil {
+			this.config.GetLogger().Warn("unable to register device group", "group", group.Name, "error", err)
+			continue
+		}
+		result[id] = info
+	}
+	return result
+}
+
+func (this *Connector) registerDeviceGroup(controller *Controller, group configuration.DeviceGroupConfig) (id string, info mgw.DeviceInfo, err error) {
+	id = controller.getGroupDeviceId(group.Name)
+	info = mgw.DeviceInfo{
+		Name:       "Z-Wave Group " + group.Name,
+		State:      mgw.Online,
+		DeviceType: group.DeviceTypeId,
+	}
+	if info.DeviceType == "" {
+		for _, nodeId := range group.NodeIds {
+			if member, ok := this.deviceRegisterGet(controller.nodeIdToDeviceId(nodeId)); ok {
+				info.DeviceType = member.DeviceType
+				break
+			}
+		}
+	}
+	if info.DeviceType == "" {
+		return id, info, errors.New("no device_type_id configured and no member registered")
+	}
+	err = this.registerDevice(id, info)
+	return id, info, err
+}
+
+func (this *Connector) createGroupCommand(controller *Controller, group configuration.DeviceGroupConfig) (result interface{}, err error) {
+	group.DeviceIdPrefix = controller.deviceIdPrefix
+	_, err = this.addDeviceGroup(group)
+	if err != nil {
+		return nil, err
+	}
+	this.updateStoredDeviceGroup(group, false)
+	id, _, err := this.registerDeviceGroup(controller, group)
+	if err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+func (this *Connector) deleteGroupCommand(controller *Controller, name string) (result interface{}, err error) {
+	id := controller.getGroupDeviceId(name)
+	this.deviceGroupsMux.Lock()
+	_, ok := this.deviceGroups[id]
+	delete(this.deviceGroups, id)
+	this.deviceGroupsMux.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown device group %v", name)
+	}
+	this.updateStoredDeviceGroup(configuration.DeviceGroupConfig{Name: name, DeviceIdPrefix: controller.deviceIdPrefix}, true)
+	if _, registered := this.deviceRegisterGet(id); registered {
+		err = this.mgwClient.RemoveDevice(id)
+		if err != nil {
+			return nil, err
+		}
+		err = this.mgwClient.StopListenToDeviceCommands(id)
+		if err != nil {
+			this.config.GetLogger().Warn("unable to stop listening to device commands", "error", err)
+		}
+		this.deviceRegisterRemove(id)
+	}
+	return id, nil
+}
+
+// expects ids from mgw (with prefixes and suffixes)
+func (this *Connector) handleGroupCommand(controller *Controller, deviceId string, serviceId string, command mgw.Command) {
+	group, ok := this.getDeviceGroup(deviceId)
+	if !ok {
+		this.config.GetLogger().Error("unknown device group", "device", deviceId)
+		this.mgwClient.SendCommandError(command.CommandId, "unknown device group "+deviceId)
+		return
+	}
+	if this.isGetServiceId(serviceId) {
+		this.mgwClient.SendCommandError(command.CommandId, "device groups only support set services")
+		return
+	}
+	var value interface{}
+	err := json.Unmarshal([]byte(command.Data), &value)
+	if err != nil {
+		this.config.GetLogger().Error("unable to Unmarshal command data to z2m value", "device", deviceId, "service", serviceId, "value", command.Data, "error", err)
+		this.mgwClient.SendCommandError(command.CommandId, "unable to Unmarshal command data to z2m value: "+err.Error())
+		return
+	}
+	err = this.writeGroupValue(controller, group, model.DecodeLocalId(serviceId), value)
+	if err != nil {
+		this.config.GetLogger().Error("unable to send value to z2m", "device", deviceId, "service", serviceId, "value", value, "error", err)
+		this.mgwClient.SendCommandError(command.CommandId, "unable to send value to z2m: "+err.Error())
+		return
+	}
+	command.Data = ""
+	err = this.mgwClient.Respond(deviceId, serviceId, command)
+	if err != nil {
+		this.config.GetLogger().Error("unable to send response to mgw", "device", deviceId, "service", serviceId, "error", err)
+		this.mgwClient.SendCommandError(command.CommandId, "unable to send response to mgw: "+err.Error())
+	}
+}
+
+// members which are known to support the value are written with one multicast, all others individually.
+// if the multicast fails, the supporting members are written individually as well.
+// broadcasts are ignored by nodes without the value; on error only the supporting nodes are written individually
+func (this *Connector) writeGroupValue(controller *Controller, group configuration.DeviceGroupConfig, localValueId string, value interface{}) (err error) {
+	nodeIds := group.NodeIds
+	if group.Broadcast {
+		nodeIds = []int64{}
+		for _, node := range this.nodeStoreGetAll(controller) {
+			nodeIds = append(nodeIds, node.NodeId)
+		}
+	}
+	supporting, individual := this.splitBySupportedValue(controller, nodeIds, localValueId)
+	switch {
+	case group.Broadcast:
+		individual = []int64{}
+		err = controller.client.WriteBroadcast(localValueId, value)
+	case len(supporting) > 1:
+		err = controller.client.WriteMulticast(supporting, localValueId, value)
+	default:
+		err = errors.New("multicast needs at least 2 supporting nodes")
+	}
+	if err != nil {
+		this.config.GetLogger().Debug("write group members individually", "group", group.Name, "reason", err)
+		individual = append(individual, supporting...)
+	}
+	err = nil
+	for _, nodeId := range individual {
+		setErr := controller.client.SetValueByValueId(strconv.FormatInt(nodeId, 10)+"-"+localValueId, value)
+		if setErr != nil {
+			err = errors.Join(err, fmt.Errorf("node %v: %w", nodeId, setErr))
+		}
+	}
+	return err
+}
+
+// supporting nodes have a writeable value with the local value id
+func (this *Connector) splitBySupportedValue(controller *Controller, nodeIds []int64, localValueId string) (supporting []int64, other []int64) {
+	serviceId := model.EncodeLocalId(localValueId)
+	for _, nodeId := range nodeIds {
+		node, ok := this.nodeStoreGet(controller, controller.nodeIdToDeviceId(nodeId))
+		supported := false
+		if ok {
+			for _, value := range node.Values {
+				if value.GetServiceId(false) == serviceId && !value.ReadOnly {
+					supported = true
+					break
+				}
+			}
+		}
+		if supported {
+			supporting = append(supporting, nodeId)
+		} else {
+			other = append(other, nodeId)
+		}
+	}
+	return supporting, other
+}
@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"time"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo"
+)
+
+// request and event service of the virtual controller device; events are sent when the device repository circuit breaker changes its state
+const ControllerServiceHealth = "health"
+
+type Health struct {
+	DeviceRepository devicerepo.CircuitState `json:"deviceRepository"` //closed, open or half-open
+	Time             int64                   `json:"time"`
+}
+
+func (this *Connector) getHealth() Health {
+	return Health{DeviceRepository: this.devicerepo.CircuitState(), Time: time.Now().UnixMilli()}
+}
+
+func (this *Connector) deviceRepositoryStateChanged(state devicerepo.CircuitState) {
+	if state == devicerepo.CircuitOpen {
+		this.config.GetLogger().Error("device repository unreachable --> pause requests", "circuit", state)
+	} else {
+		this.config.GetLogger().Info("device repository circuit breaker state changed", "circuit", state)
+	}
+	if this.mgwClient == nil {
+		return
+	}
+	if state == devicerepo.CircuitOpen {
+		this.mgwClient.SendClientError("device repository unreachable; requests are paused")
+	}
+	health := this.getHealth()
+	for _, controller := range this.controllers {
+		deviceId := controller.getControllerDeviceId()
+		if _, ok := this.deviceRegisterGet(deviceId); !ok {
+			continue
+		}
+		err := this.mgwClient.MarshalAndSendEvent(deviceId, ControllerServiceHealth, health)
+		if err != nil {
+			this.config.GetLogger().Error("unable to send event", "device", deviceId, "service", ControllerServiceHealth, "error", err)
+			this.mgwClient.SendClientError("unable to send event: " + err.Error())
+		}
+	}
+}
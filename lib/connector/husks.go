@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"fmt"
+	"time"
+)
+
+const DefaultHuskRecoveryBackoff = 10 * time.Minute
+
+type huskAction int
+
+const (
+	huskWait huskAction = iota
+	huskReInterview
+	huskGiveUp
+)
+
+type huskRecoveryState struct {
+	attempts    int
+	nextAttempt time.Time
+	reported    bool //the failed recovery has been sent as device error
+}
+
+// re-interviews husks (nodes without manufacturer/product info) with exponential backoff and reports them as device errors;
+// returns the husks whose recovery failed and which may be deleted. The re-interviews run in the background.
+// if husk_recovery_attempts is not set, all husks are returned
+func (this *Connector) recoverHusks(controller *Controller, huskIds []int64) (failed []int64) {
+	maxAttempts := this.config.HuskRecoveryAttempts
+	if maxAttempts <= 0 {
+		return huskIds
+	}
+	backoff := this.config.HuskRecoveryBackoff.GetDuration()
+	if backoff == 0 {
+		backoff = DefaultHuskRecoveryBackoff
+	}
+	now := time.Now()
+
+	controller.nodeTaskMux.Lock()
+	states := map[int64]huskRecoveryState{}
+	reInterviews := []int64{}
+	giveUpReports := []int64{}
+	for _, huskId := range huskIds {
+		//nodes which are no husks anymore are dropped from the states
+		state, action := nextHuskAction(controller.husks[huskId], now, maxAttempts, backoff)
+		switch action {
+		case huskReInterview:
+			reInterviews = append(reInterviews, huskId)
+		case huskGiveUp:
+			failed = append(failed, huskId)
+			if !state.reported {
+				state.reported = true
+				giveUpReports = append(giveUpReports, huskId)
+			}
+		}
+		states[huskId] = state
+	}
+	controller.husks = states
+	controller.nodeTaskMux.Unlock()
+
+	for _, huskId := range giveUpReports {
+		deviceId := controller.nodeIdToDeviceId(huskId)
+		this.config.GetLogger().Warn("unable to recover husk", "device", deviceId, "attempts", states[huskId].attempts)
+		this.mgwClient.SendDeviceError(deviceId, fmt.Sprintf("node interview incomplete (husk); recovery failed after %v re-interviews", states[huskId].attempts))
+	}
+
+	//each re-interview may wait for the zwave api timeout; the device info listener must not be blocked
+	if len(reInterviews) > 0 {
+		go func() {
+			for _, huskId := range reInterviews {
+				deviceId := controller.nodeIdToDeviceId(huskId)
+				attempt := states[huskId].attempts
+				this.config.GetLogger().Warn("try to recover husk by re-interview", "device", deviceId, "attempt", attempt)
+				msg := fmt.Sprintf("node interview incomplete (husk); re-interview attempt %v of %v", attempt, maxAttempts)
+				_, err := this.reInterview(controller, huskId)
+				if err != nil {
+					msg = msg + " failed: " + err.Error()
+				}
+				this.mgwClient.SendDeviceError(deviceId, msg)
+			}
+		}()
+	}
+	return failed
+}
+
+// the last attempt gets one backoff period to complete before the recovery is given up
+func nextHuskAction(state huskRecoveryState, now time.Time, maxAttempts int, backoff time.Duration) (huskRecoveryState, huskAction) {
+	if now.Before(state.nextAttempt) {
+		return state, huskWait
+	}
+	if state.attempts >= maxAttempts {
+		return state, huskGiveUp
+	}
+	state.attempts = state.attempts + 1
+	state.nextAttempt = now.Add(backoff * time.Duration(1<<(state.attempts-1)))
+	return state, huskReInterview
+}
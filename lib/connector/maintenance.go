@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+type LifelineHealthInput struct {
+	Rounds int64 `json:"rounds"` //0 uses the gateway default
+}
+
+func (this *Connector) pingCommand(controller *Controller, _ string, nodeId int64, _ string) (result interface{}, err error) {
+	return controller.client.PingNode(nodeId)
+}
+
+func (this *Connector) refreshValuesCommand(controller *Controller, _ string, nodeId int64, _ string) (result interface{}, err error) {
+	return controller.client.RefreshValues(nodeId)
+}
+
+func (this *Connector) reInterviewCommand(controller *Controller, _ string, nodeId int64, _ string) (result interface{}, err error) {
+	return this.reInterview(controller, nodeId)
+}
+
+func (this *Connector) checkLifelineHealthCommand(controller *Controller, _ string, nodeId int64, data string) (result interface{}, err error) {
+	input := LifelineHealthInput{}
+	if data != "" {
+		err = json.Unmarshal([]byte(data), &input)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal command data: %w", err)
+		}
+	}
+	return controller.client.CheckLifelineHealth(nodeId, input.Rounds)
+}
@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"maps"
+	"time"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo"
+)
+
+const DefaultTypeMappingFileCheckInterval = 10 * time.Second
+
+func (this *Connector) getTypeMappings() (deviceTypeMapping map[string]string, nodeDeviceTypeOverwrite map[string]string) {
+	this.typeMappingMux.Lock()
+	defer this.typeMappingMux.Unlock()
+	return this.deviceTypeMapping, this.nodeDeviceTypeOverwrite
+}
+
+// loads device_type_mapping_file and replaces the mappings of the connector, if the file changed;
+// on errors the current mappings are kept
+func (this *Connector) loadTypeMappingFile() (changed bool, err error) {
+	file, content, err := configuration.LoadDeviceTypeMappingFile(this.config.DeviceTypeMappingFile)
+	if err != nil {
+		return false, err
+	}
+	this.typeMappingMux.Lock()
+	defer this.typeMappingMux.Unlock()
+	if this.typeMappingFileContent != nil && bytes.Equal(this.typeMappingFileContent, content) {
+		return false, nil
+	}
+	deviceTypeMapping := maps.Clone(this.config.DeviceTypeMapping)
+	if deviceTypeMapping == nil {
+		deviceTypeMapping = map[string]string{}
+	}
+	maps.Copy(deviceTypeMapping, file.DeviceTypeMapping)
+	nodeDeviceTypeOverwrite := maps.Clone(this.config.NodeDeviceTypeOverwrite)
+	if nodeDeviceTypeOverwrite == nil {
+		nodeDeviceTypeOverwrite = map[string]string{}
+	}
+	maps.Copy(nodeDeviceTypeOverwrite, file.NodeDeviceTypeOverwrite)
+
+	this.deviceTypeMapping = canonicalizeMappingKeys(deviceTypeMapping, this.config.GetLogger())
+	this.nodeDeviceTypeOverwrite = nodeDeviceTypeOverwrite
+	this.typeMappingFileContent = content
+	return true, nil
+}
+
+func (this *Connector) watchTypeMappingFile(ctx context.Context) {
+	if this.config.DeviceTypeMappingFile == "" {
+		return
+	}
+	interval := this.config.DeviceTypeMappingFileCheckInterval.GetDuration()
+	if interval == 0 {
+		interval = DefaultTypeMappingFileCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				changed, err := this.loadTypeMappingFile()
+				if err != nil {
+					this.config.GetLogger().Error("unable to reload device type mapping file; keep current mapping", "file", this.config.DeviceTypeMappingFile, "error", err)
+					continue
+				}
+				if changed {
+					this.config.GetLogger().Info("reloaded device type mapping file", "file", this.config.DeviceTypeMappingFile)
+					this.reRegisterChangedDeviceTypes()
+				}
+			}
+		}
+	}()
+}
+
+// registers known devices again whose device type differs from the registered one
+func (this *Connector) reRegisterChangedDeviceTypes() {
+	for _, controller := range this.controllers {
+		for _, node := range this.nodeStoreGetAll(controller) {
+			if !this.nodeIsExposed(node) {
+				continue
+			}
+			devices, err := this.splitNodeByEndpoints(this.filterNodeValues(node))
+			if errors.Is(err, devicerepo.ErrDeviceTypeListPending) {
+				this.deferNode(controller, node.NodeId)
+				continue
+			}
+			for _, device := range devices {
+				id, info, err := this.nodeToDeviceInfo(controller, device)
+				if errors.Is(err, devicerepo.ErrDeviceTypeListPending) {
+					this.deferNode(controller, node.NodeId)
+					continue
+				}
+				if err != nil {
+					this.config.GetLogger().Error("unable to create device info for node", "error", err)
+					continue
+				}
+				registered, ok := this.deviceRegisterGet(id)
+				if !ok || registered.DeviceType == info.DeviceType {
+					continue
+				}
+				this.config.GetLogger().Info("register device with new device type", "device", id, "old", registered.DeviceType, "new", info.DeviceType)
+				info.State = registered.State
+				err = this.registerDevice(id, info)
+				if err != nil {
+					this.config.GetLogger().Error("unable to register device", "device", id, "error", err)
+				}
+			}
+		}
+	}
+}
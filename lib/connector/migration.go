@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+// DefaultServiceIdMigrations is the curated zwave2mqtt (OpenZWave 1.6 indexes) to zwavejs2mqtt table
+var DefaultServiceIdMigrations = []configuration.ServiceIdMigration{
+	{CommandClass: 37, Index: 0, Property: "currentValue", TargetProperty: "targetValue"}, //Binary Switch
+	{CommandClass: 38, Index: 0, Property: "currentValue", TargetProperty: "targetValue"}, //Multilevel Switch
+	{CommandClass: 48, Index: 0, Property: "Any"},                                         //Binary Sensor
+	{CommandClass: 49, Index: 1, Property: "Air temperature"},                             //Multilevel Sensor
+	{CommandClass: 49, Index: 3, Property: "Illuminance"},
+	{CommandClass: 49, Index: 4, Property: "Power"},
+	{CommandClass: 49, Index: 5, Property: "Humidity"},
+	{CommandClass: 49, Index: 27, Property: "Ultraviolet"},
+	{CommandClass: 50, Index: 0, Property: "value-65537"}, //Meter: electric kWh
+	{CommandClass: 50, Index: 1, Property: "value-65793"}, //Meter: electric kVAh
+	{CommandClass: 50, Index: 2, Property: "value-66049"}, //Meter: electric W
+	{CommandClass: 50, Index: 4, Property: "value-66561"}, //Meter: electric V
+	{CommandClass: 50, Index: 5, Property: "value-66817"}, //Meter: electric A
+	{CommandClass: 64, Index: 0, Property: "mode"},        //Thermostat Mode
+	{CommandClass: 67, Index: 1, Property: "setpoint-1"},  //Thermostat Setpoint: heating
+	{CommandClass: 67, Index: 2, Property: "setpoint-2"},  //Thermostat Setpoint: cooling
+	{CommandClass: 128, Index: 0, Property: "level"},      //Battery
+}
+
+type serviceIdMigrator struct {
+	byProperty map[string]configuration.ServiceIdMigration //<cc>-<property>, reported and target properties
+	byIndex    map[string]configuration.ServiceIdMigration //<cc>-<index>
+	unmapped   map[string]bool
+	mux        sync.Mutex
+}
+
+// config entries take precedence over the built-in table
+func newServiceIdMigrator(migrations []configuration.ServiceIdMigration) *serviceIdMigrator {
+	result := &serviceIdMigrator{
+		byProperty: map[string]configuration.ServiceIdMigration{},
+		byIndex:    map[string]configuration.ServiceIdMigration{},
+		unmapped:   map[string]bool{},
+	}
+	for _, migration := range append(append([]configuration.ServiceIdMigration{}, DefaultServiceIdMigrations...), migrations...) {
+		cc := strconv.FormatInt(migration.CommandClass, 10)
+		result.byIndex[cc+"-"+strconv.FormatInt(migration.Index, 10)] = migration
+		result.byProperty[cc+"-"+migration.Property] = migration
+		if migration.TargetProperty != "" {
+			result.byProperty[cc+"-"+migration.TargetProperty] = migration
+		}
+	}
+	return result
+}
+
+// LegacyServiceId returns the zwave2mqtt service id of a zwavejs2mqtt service id (both encoded, optional :get suffix)
+func (this *serviceIdMigrator) LegacyServiceId(serviceId string) (legacy string, ok bool) {
+	local, suffix := splitGetSuffix(serviceId)
+	parts := strings.SplitN(model.DecodeLocalId(local), "-", 3)
+	if len(parts) != 3 {
+		return "", false
+	}
+	endpoint, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return "", false
+	}
+	migration, ok := this.byProperty[parts[0]+"-"+parts[2]]
+	if !ok {
+		return "", false
+	}
+	return parts[0] + "-" + strconv.FormatInt(endpoint+1, 10) + "-" + strconv.FormatInt(migration.Index, 10) + suffix, true
+}
+
+// CurrentServiceId returns the zwavejs2mqtt service id of a zwave2mqtt service id (both encoded, optional :get suffix); set services use the target property
+func (this *serviceIdMigrator) CurrentServiceId(legacyServiceId string) (current string, ok bool) {
+	local, suffix := splitGetSuffix(legacyServiceId)
+	parts := strings.Split(local, "-")
+	if len(parts) != 3 {
+		return "", false
+	}
+	instance, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || instance < 1 {
+		return "", false
+	}
+	migration, ok := this.byIndex[parts[0]+"-"+parts[2]]
+	if !ok {
+		return "", false
+	}
+	property := migration.Property
+	if suffix == "" && migration.TargetProperty != "" {
+		property = migration.TargetProperty
+	}
+	return model.EncodeLocalId(parts[0]+"-"+strconv.FormatInt(instance-1, 10)+"-"+property) + suffix, true
+}
+
+// returns true the first time a service id is reported as unmapped
+func (this *serviceIdMigrator) markUnmapped(serviceId string) bool {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	if this.unmapped[serviceId] {
+		return false
+	}
+	this.unmapped[serviceId] = true
+	return true
+}
+
+func splitGetSuffix(serviceId string) (local string, suffix string) {
+	if strings.HasSuffix(serviceId, ":get") {
+		return strings.TrimSuffix(serviceId, ":get"), ":get"
+	}
+	return serviceId, ""
+}
+
+// sends the event additionally under the legacy service id, if service_id_transition is enabled and the controller is zwavejs2mqtt
+func (this *Connector) sendLegacyEvent(controller *Controller, deviceId string, serviceId string, value interface{}) {
+	if !this.config.ServiceIdTransition || controller.config.ZwaveController != "zwavejs2mqtt" {
+		return
+	}
+	legacy, ok := this.serviceIdMigrator.LegacyServiceId(serviceId)
+	if !ok {
+		if this.serviceIdMigrator.markUnmapped(serviceId) {
+			this.config.GetLogger().Warn("no legacy service id known", "service", serviceId)
+		}
+		return
+	}
+	this.saveValue(deviceId, legacy, value)
+	err := this.mgwClient.MarshalAndSendEvent(deviceId, legacy, value)
+	if err != nil {
+		this.config.GetLogger().Error("unable to send event", "device", deviceId, "service", legacy, "error", err)
+		this.mgwClient.SendClientError("unable to send event: " + err.Error())
+	}
+}
+
+// translates legacy set service ids, if service_id_transition is enabled and the controller is zwavejs2mqtt
+func (this *Connector) migrateCommandServiceId(controller *Controller, serviceId string) string {
+	if !this.config.ServiceIdTransition || controller.config.ZwaveController != "zwavejs2mqtt" {
+		return serviceId
+	}
+	if current, ok := this.serviceIdMigrator.CurrentServiceId(serviceId); ok {
+		return current
+	}
+	return serviceId
+}
@@ -0,0 +1,229 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+	"text/template"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+const (
+	NameConflictWinnerGateway = "gateway"
+	NameConflictWinnerMgw     = "mgw"
+)
+
+type NamingInput struct {
+	Name     string `json:"name"`
+	Location string `json:"location"`
+}
+
+// NameTemplateFields are the fields available in device_name_template
+type NameTemplateFields struct {
+	Name         string //gateway name; endpoint devices append the endpoint
+	Location     string
+	Manufacturer string
+	Product      string
+	NodeId       int64
+	Endpoint     int64
+	MappingKey   string
+	DeviceId     string //<nodeId> or <nodeId>.<endpoint>
+}
+
+func parseNameTemplate(text string) (*template.Template, error) {
+	if text == "" {
+		return nil, nil
+	}
+	result, err := template.New("device_name_template").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid device_name_template: %w", err)
+	}
+	return result, nil
+}
+
+// the name from device_name_template or, without template, the gateway name; falls back to getDefaultName if empty
+func (this *Connector) renderDeviceName(node model.DeviceInfo) string {
+	name := node.Name
+	if this.nameTemplate != nil {
+		buf := bytes.Buffer{}
+		err := this.nameTemplate.Execute(&buf, NameTemplateFields{
+			Name:         node.Name,
+			Location:     node.Location,
+			Manufacturer: node.Manufacturer,
+			Product:      node.Product,
+			NodeId:       node.NodeId,
+			Endpoint:     node.Endpoint,
+			MappingKey:   node.GetTypeMappingKey(),
+			DeviceId:     node.GetRawDeviceId(),
+		})
+		if err != nil {
+			this.config.GetLogger().Warn("unable to execute device_name_template", "node", node.NodeId, "error", err)
+			buf.Reset()
+		}
+		name = strings.TrimSpace(buf.String())
+	}
+	if name == "" {
+		name = getDefaultName(node)
+	}
+	return name
+}
+
+// the rendered name is used unless the device has been renamed with the rename service (mgw name) and the rename is still valid:
+// the rendered gateway name is unchanged since the rename, the gateway reports the new name or name_conflict_winner is "mgw".
+// a later gateway side change with name_conflict_winner "gateway" discards the rename.
+// with name_sync, the resulting name of a node device is pushed to the gateway once per change if it differs from the gateway name;
+// names created by the template are not pushed.
+func (this *Connector) resolveDeviceName(controller *Controller, id string, node model.DeviceInfo) (name string) {
+	rendered := this.renderDeviceName(node)
+	name = rendered
+	if renamed, ok := this.getStoredDeviceName(id); ok && renamed.Name != rendered {
+		switch {
+		case node.Name == renamed.Name:
+			//the gateway adopted the rename; later changes are compared with the current rendered name
+			name = renamed.Name
+			if renamed.Rendered != rendered {
+				renamed.Rendered = rendered
+				this.setStoredDeviceName(id, &renamed)
+			}
+		case renamed.Rendered == rendered || this.config.NameConflictWinner == NameConflictWinnerMgw:
+			name = renamed.Name
+		default:
+			this.config.GetLogger().Info("gateway name changed after rename --> use gateway name", "device", id, "name", rendered, "previous", renamed.Name)
+			this.setStoredDeviceName(id, nil)
+		}
+	}
+	if this.config.NameSync && node.Endpoint == 0 && name != node.Name && (this.nameTemplate == nil || name != rendered) && this.markNodeNamePushed(id, name) {
+		go this.pushNodeName(controller, node.NodeId, name)
+	}
+	return name
+}
+
+// storedDeviceName is a name set with the rename service; stored in the fallback file
+type storedDeviceName struct {
+	Name     string `json:"name"`
+	Rendered string `json:"rendered"` //rendered gateway name at the time of the rename; a different rendered name means a gateway side change
+}
+
+func (this *Connector) getStoredDeviceName(id string) (result storedDeviceName, ok bool) {
+	this.deviceNamesMux.Lock()
+	defer this.deviceNamesMux.Unlock()
+	this.loadStoredDeviceNames()
+	result, ok = this.deviceNames[id]
+	return result, ok
+}
+
+// name == nil removes the stored name
+func (this *Connector) setStoredDeviceName(id string, name *storedDeviceName) {
+	this.deviceNamesMux.Lock()
+	defer this.deviceNamesMux.Unlock()
+	this.loadStoredDeviceNames()
+	if name == nil {
+		delete(this.deviceNames, id)
+	} else {
+		this.deviceNames[id] = *name
+	}
+	this.setStored(StoreKeyDeviceNames, maps.Clone(this.deviceNames))
+}
+
+// expects a locked deviceNamesMux
+func (this *Connector) loadStoredDeviceNames() {
+	if this.deviceNames != nil {
+		return
+	}
+	this.deviceNames, _ = getStored[map[string]storedDeviceName](this, StoreKeyDeviceNames)
+	if this.deviceNames == nil {
+		this.deviceNames = map[string]storedDeviceName{}
+	}
+}
+
+// returns false if the name has already been pushed for the device
+func (this *Connector) markNodeNamePushed(id string, name string) bool {
+	this.deviceNamesMux.Lock()
+	defer this.deviceNamesMux.Unlock()
+	if this.pushedNodeNames == nil {
+		this.pushedNodeNames = map[string]string{}
+	}
+	if pushed, ok := this.pushedNodeNames[id]; ok && pushed == name {
+		return false
+	}
+	this.pushedNodeNames[id] = name
+	return true
+}
+
+func (this *Connector) pushNodeName(controller *Controller, nodeId int64, name string) {
+	_, err := controller.client.SetNodeName(nodeId, name)
+	if errors.Is(err, model.ErrNotSupported) {
+		this.config.GetLogger().Debug("gateway does not support node names", "controller", controller.deviceIdPrefix, "node", nodeId)
+	} else if err != nil {
+		this.config.GetLogger().Warn("unable to push node name to gateway", "controller", controller.deviceIdPrefix, "node", nodeId, "error", err)
+	}
+}
+
+func parseNamingInput(data string) (input NamingInput, err error) {
+	err = json.Unmarshal([]byte(data), &input)
+	if err != nil {
+		return input, fmt.Errorf("unable to unmarshal command data: %w", err)
+	}
+	return input, nil
+}
+
+// renames the device in the mgw and, for node devices, in the gateway; endpoints have no gateway name
+func (this *Connector) renameCommand(controller *Controller, deviceId string, nodeId int64, data string) (result interface{}, err error) {
+	input, err := parseNamingInput(data)
+	if err != nil {
+		return nil, err
+	}
+	if input.Name == "" {
+		return nil, errors.New("missing name")
+	}
+	if deviceId == controller.nodeIdToDeviceId(nodeId) {
+		result, err = controller.client.SetNodeName(nodeId, input.Name)
+		if err != nil && !errors.Is(err, model.ErrNotSupported) {
+			return nil, err
+		}
+	}
+	info, ok := this.deviceRegisterGet(deviceId)
+	if !ok {
+		return nil, fmt.Errorf("unknown device %v", deviceId)
+	}
+	renamed := storedDeviceName{Name: input.Name, Rendered: info.Name}
+	if previous, ok := this.getStoredDeviceName(deviceId); ok {
+		renamed.Rendered = previous.Rendered
+	}
+	info.Name = input.Name
+	err = this.mgwClient.SetDevice(deviceId, info)
+	if err != nil {
+		return nil, err
+	}
+	this.deviceRegisterSet(deviceId, info)
+	this.setStoredDeviceName(deviceId, &renamed)
+	return result, nil
+}
+
+func (this *Connector) setLocationCommand(controller *Controller, _ string, nodeId int64, data string) (result interface{}, err error) {
+	input, err := parseNamingInput(data)
+	if err != nil {
+		return nil, err
+	}
+	return controller.client.SetNodeLocation(nodeId, input.Location)
+}
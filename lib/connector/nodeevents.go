@@ -0,0 +1,57 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import "strings"
+
+// NodeEventListener receives the node events of the zwave gateway (not the value events)
+func (this *Connector) NodeEventListener(controller *Controller, event string, nodeId int64, data []interface{}) {
+	switch {
+	case strings.HasPrefix(event, "firmware update"):
+		this.handleFirmwareUpdateEvent(controller, event, nodeId, data)
+	case event == "interview completed":
+		this.handleInterviewCompleted(controller, nodeId)
+	}
+}
+
+// starts a re-interview; the device info is requested again when the interview is completed
+func (this *Connector) reInterview(controller *Controller, nodeId int64) (result interface{}, err error) {
+	controller.nodeTaskMux.Lock()
+	controller.pendingInterviews[nodeId] = true
+	controller.nodeTaskMux.Unlock()
+	result, err = controller.client.RefreshInfo(nodeId)
+	if err != nil {
+		controller.nodeTaskMux.Lock()
+		delete(controller.pendingInterviews, nodeId)
+		controller.nodeTaskMux.Unlock()
+	}
+	return result, err
+}
+
+func (this *Connector) handleInterviewCompleted(controller *Controller, nodeId int64) {
+	controller.nodeTaskMux.Lock()
+	pending := controller.pendingInterviews[nodeId]
+	delete(controller.pendingInterviews, nodeId)
+	controller.nodeTaskMux.Unlock()
+	if pending {
+		this.config.GetLogger().Info("interview completed --> request device info update", "controller", controller.deviceIdPrefix, "node", nodeId)
+		err := controller.client.RequestDeviceInfoUpdate()
+		if err != nil {
+			this.config.GetLogger().Error("unable to request device info update", "error", err)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+// the node store holds the last known node info (incl. value metadata) per node device id
+
+// returns the stored node; infos without values are merged with the known node
+func (this *Connector) nodeStoreSet(controller *Controller, node model.DeviceInfo, withValues bool) model.DeviceInfo {
+	deviceId := controller.nodeIdToDeviceId(node.NodeId)
+	this.nodeStoreMux.Lock()
+	defer this.nodeStoreMux.Unlock()
+	if existing, ok := this.nodeStore[deviceId]; ok && !withValues {
+		//infos without values (e.g. from node available events) only update the identification
+		existing.Name = node.Name
+		existing.Location = node.Location
+		existing.Manufacturer = node.Manufacturer
+		existing.ManufacturerId = node.ManufacturerId
+		existing.Product = node.Product
+		existing.ProductType = node.ProductType
+		existing.ProductId = node.ProductId
+		node = existing
+	}
+	this.nodeStore[deviceId] = node
+	return node
+}
+
+// expects ids from mgw (with prefixes); endpoint device ids return the info of their node
+func (this *Connector) nodeStoreGet(controller *Controller, deviceId string) (node model.DeviceInfo, ok bool) {
+	this.nodeStoreMux.Lock()
+	defer this.nodeStoreMux.Unlock()
+	node, ok = this.nodeStore[controller.addDeviceIdPrefix(controller.deviceIdToRawNodeId(deviceId))]
+	return
+}
+
+func (this *Connector) nodeStoreGetAll(controller *Controller) (result []model.DeviceInfo) {
+	this.nodeStoreMux.Lock()
+	defer this.nodeStoreMux.Unlock()
+	for deviceId, node := range this.nodeStore {
+		if owner, ok := this.getControllerByDeviceId(deviceId); ok && owner == controller {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// online devices which were dead are set to alive; asleep nodes are not distinguished from alive nodes by the device state events
+func (this *Connector) nodeStoreSetOnline(controller *Controller, nodeId int64, online bool) {
+	deviceId := controller.nodeIdToDeviceId(nodeId)
+	this.nodeStoreMux.Lock()
+	defer this.nodeStoreMux.Unlock()
+	node, ok := this.nodeStore[deviceId]
+	if !ok {
+		return
+	}
+	if !online {
+		node.Status = model.NodeStatusDead
+	} else if node.Status == model.NodeStatusDead {
+		node.Status = model.NodeStatusAlive
+	}
+	this.nodeStore[deviceId] = node
+}
+
+func (this *Connector) nodeStoreUpdateValue(controller *Controller, value model.Value) {
+	deviceId := controller.nodeIdToDeviceId(value.NodeId)
+	this.nodeStoreMux.Lock()
+	defer this.nodeStoreMux.Unlock()
+	node, ok := this.nodeStore[deviceId]
+	if !ok {
+		return
+	}
+	values := map[string]model.Value{}
+	key := value.ValueId
+	for k, v := range node.Values {
+		values[k] = v
+		if v.ValueId == value.ValueId {
+			key = k
+		}
+	}
+	values[key] = value
+	node.Values = values
+	this.nodeStore[deviceId] = node
+}
+
+func (this *Connector) nodeStoreRemove(deviceId string) {
+	this.nodeStoreMux.Lock()
+	defer this.nodeStoreMux.Unlock()
+	delete(this.nodeStore, deviceId)
+}
+
+// expects ids from mgw (with prefixes); returns the node id of node and endpoint devices
+func (this *Controller) deviceIdToNodeId(deviceId string) (int64, error) {
+	nodeId, err := strconv.ParseInt(this.deviceIdToRawNodeId(deviceId), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("device %v is not a z-wave node: %w", deviceId, err)
+	}
+	return nodeId, nil
+}
@@ -0,0 +1,250 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+const PollingTick = time.Second
+
+// polls wait at most polling_timeout (default DefaultPollTimeout) for the gateway response, so that unresponsive nodes block a poll slot
+// for a limited time; the gateway still publishes the polled value as value event
+const DefaultPollTimeout = 5 * time.Second
+
+const MaxConcurrentPolls = 4
+
+type pollTask struct {
+	controller *Controller
+	nodeId     int64
+	valueId    string
+	interval   time.Duration
+	next       time.Time
+	failing    bool //the last poll failed; repeated failures are logged at debug level
+}
+
+type pollScheduler struct {
+	tasks       map[string]*pollTask //by <prefix>:<value id>
+	unsupported map[*Controller]bool //controllers without pollValue api; no tasks are scheduled
+	mux         sync.Mutex
+	slots       chan struct{} //limits concurrent polls to MaxConcurrentPolls
+	timeout     time.Duration
+}
+
+func (this *Connector) startPolling(ctx context.Context) {
+	if len(this.config.PollingRules) == 0 {
+		return
+	}
+	this.polling.slots = make(chan struct{}, MaxConcurrentPolls)
+	this.polling.timeout = this.config.PollingTimeout.GetDuration()
+	if this.polling.timeout <= 0 {
+		this.polling.timeout = DefaultPollTimeout
+	}
+	go func() {
+		ticker := time.NewTicker(PollingTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				for _, task := range this.getDuePollTasks(now) {
+					select {
+					case this.polling.slots <- struct{}{}:
+						go func() {
+							defer func() { <-this.polling.slots }()
+							this.poll(task)
+						}()
+					default:
+						this.postponePollTask(task)
+					}
+				}
+			}
+		}
+	}()
+}
+
+// updates the poll tasks of the controller after a full node list is known; existing tasks keep their schedule;
+// values hidden by filters are not polled
+func (this *Connector) updatePollTasks(controller *Controller, nodes []model.DeviceInfo) {
+	if len(this.config.PollingRules) == 0 {
+		return
+	}
+	wanted := map[string]*pollTask{}
+	for _, node := range nodes {
+		if !this.nodeIsExposed(node) {
+			continue
+		}
+		for _, value := range this.filterNodeValues(node).Values {
+			interval := this.getPollInterval(controller, node, value)
+			if interval <= 0 {
+				continue
+			}
+			key := controller.deviceIdPrefix + ":" + value.ValueId
+			wanted[key] = &pollTask{
+				controller: controller,
+				nodeId:     node.NodeId,
+				valueId:    value.ValueId,
+				interval:   interval,
+				next:       time.Now().Add(spreadOffset(key, interval)),
+			}
+		}
+	}
+	this.polling.mux.Lock()
+	defer this.polling.mux.Unlock()
+	if this.polling.unsupported[controller] {
+		return
+	}
+	if this.polling.tasks == nil {
+		this.polling.tasks = map[string]*pollTask{}
+	}
+	for key, task := range this.polling.tasks {
+		if task.controller != controller {
+			continue
+		}
+		if update, ok := wanted[key]; ok && update.interval == task.interval {
+			wanted[key] = task
+		} else if !ok {
+			delete(this.polling.tasks, key)
+		}
+	}
+	for key, task := range wanted {
+		this.polling.tasks[key] = task
+	}
+}
+
+// the smallest interval of all matching rules; 0 if no rule matches
+func (this *Connector) getPollInterval(controller *Controller, node model.DeviceInfo, value model.Value) (result time.Duration) {
+	if value.WriteOnly || value.ValueId == "" {
+		return 0
+	}
+	deviceTypeId := ""
+	if info, ok := this.deviceRegisterGet(this.getDeviceIdForValue(controller, value)); ok {
+		deviceTypeId = info.DeviceType
+	}
+	for _, rule := range this.config.PollingRules {
+		if rule.DeviceTypeId != "" && rule.DeviceTypeId != deviceTypeId {
+			continue
+		}
+		if rule.MappingKey != "" && !model.TypeMappingKeysMatch(rule.MappingKey, node.GetTypeMappingKey()) {
+			continue
+		}
+		if len(rule.ServiceIds) > 0 && !slices.Contains(rule.ServiceIds, value.GetServiceId(false)) {
+			continue
+		}
+		interval := rule.Interval.GetDuration()
+		if interval > 0 && (result == 0 || interval < result) {
+			result = interval
+		}
+	}
+	return result
+}
+
+// stable offset within the interval, so that polls of many values do not happen at the same time
+func spreadOffset(key string, interval time.Duration) time.Duration {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	return time.Duration(h.Sum64() % uint64(interval))
+}
+
+func (this *Connector) getDuePollTasks(now time.Time) (result []pollTask) {
+	this.polling.mux.Lock()
+	defer this.polling.mux.Unlock()
+	for _, task := range this.polling.tasks {
+		if !now.Before(task.next) {
+			result = append(result, *task)
+			task.next = now.Add(task.interval)
+		}
+	}
+	slices.SortFunc(result, func(a, b pollTask) int {
+		return a.next.Compare(b.next)
+	})
+	return result
+}
+
+// all poll slots are in use; the task is due again on the next tick
+func (this *Connector) postponePollTask(task pollTask) {
+	this.polling.mux.Lock()
+	defer this.polling.mux.Unlock()
+	if current, ok := this.polling.tasks[task.controller.deviceIdPrefix+":"+task.valueId]; ok && current.controller == task.controller {
+		current.next = time.Now()
+	}
+}
+
+func (this *Connector) poll(task pollTask) {
+	node, ok := this.nodeStoreGet(task.controller, task.controller.nodeIdToDeviceId(task.nodeId))
+	if !ok || !node.CanBePolled() {
+		this.config.GetLogger().Debug("skip poll of sleeping or unknown node", "controller", task.controller.deviceIdPrefix, "node", task.nodeId, "value", task.valueId)
+		return
+	}
+	_, err := task.controller.client.PollValue(task.valueId, this.polling.timeout)
+	if errors.Is(err, model.ErrNotSupported) {
+		if this.stopPollTasks(task.controller) {
+			this.config.GetLogger().Warn("polling not supported by controller --> remove poll tasks", "controller", task.controller.deviceIdPrefix, "error", err)
+		}
+		return
+	}
+	if !this.setPollTaskFailing(task, err != nil) {
+		if err != nil {
+			this.config.GetLogger().Debug("unable to poll value", "controller", task.controller.deviceIdPrefix, "node", task.nodeId, "value", task.valueId, "error", err)
+		}
+		return
+	}
+	if err != nil {
+		this.config.GetLogger().Warn("unable to poll value", "controller", task.controller.deviceIdPrefix, "node", task.nodeId, "value", task.valueId, "error", err)
+	} else {
+		this.config.GetLogger().Info("poll value succeeded again", "controller", task.controller.deviceIdPrefix, "node", task.nodeId, "value", task.valueId)
+	}
+}
+
+// returns true if the failing state of the task changed
+func (this *Connector) setPollTaskFailing(task pollTask, failing bool) bool {
+	this.polling.mux.Lock()
+	defer this.polling.mux.Unlock()
+	current, ok := this.polling.tasks[task.controller.deviceIdPrefix+":"+task.valueId]
+	if !ok || current.controller != task.controller || current.failing == failing {
+		return false
+	}
+	current.failing = failing
+	return true
+}
+
+// removes the tasks of a controller without pollValue api; returns false if already stopped
+func (this *Connector) stopPollTasks(controller *Controller) bool {
+	this.polling.mux.Lock()
+	defer this.polling.mux.Unlock()
+	if this.polling.unsupported[controller] {
+		return false
+	}
+	if this.polling.unsupported == nil {
+		this.polling.unsupported = map[*Controller]bool{}
+	}
+	this.polling.unsupported[controller] = true
+	for key, task := range this.polling.tasks {
+		if task.controller == controller {
+			delete(this.polling.tasks, key)
+		}
+	}
+	return true
+}
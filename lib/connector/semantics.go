@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+// DefaultSemanticRules annotates common sensor, meter, switch and thermostat values (zwavejs2mqtt properties);
+// the names are resolved by semantic_ids or by the names of the functions, aspects and characteristics of the device repository;
+// rules with unresolved functions are skipped
+var DefaultSemanticRules = []configuration.SemanticRule{
+	{CommandClass: 49, Property: "Air temperature", Unit: "°C", MeasuringFunction: "getTemperature", Aspect: "air", Characteristic: "celsius"},
+	{CommandClass: 49, Property: "Air temperature", Unit: "°F", MeasuringFunction: "getTemperature", Aspect: "air", Characteristic: "fahrenheit"},
+	{CommandClass: 49, Property: "Humidity", Unit: "%", MeasuringFunction: "getHumidity", Aspect: "air", Characteristic: "percentage"},
+	{CommandClass: 49, Property: "Illuminance", Unit: "Lux", MeasuringFunction: "getIlluminance", Aspect: "lighting", Characteristic: "lux"},
+	{CommandClass: 49, Property: "Power", Unit: "W", MeasuringFunction: "getPower", Aspect: "electricity", Characteristic: "watt"},
+	{CommandClass: 49, Property: "Ultraviolet", MeasuringFunction: "getUltravioletIndex", Aspect: "air", Characteristic: "uvIndex"},
+	{CommandClass: 50, Property: "value", PropertyKey: "65537", MeasuringFunction: "getEnergyConsumption", Aspect: "electricity", Characteristic: "kilowattHour"},
+	{CommandClass: 50, Property: "value", PropertyKey: "66049", MeasuringFunction: "getPower", Aspect: "electricity", Characteristic: "watt"},
+	{CommandClass: 50, Property: "value", PropertyKey: "66561", MeasuringFunction: "getVoltage", Aspect: "electricity", Characteristic: "volt"},
+	{CommandClass: 50, Property: "value", PropertyKey: "66817", MeasuringFunction: "getElectricCurrent", Aspect: "electricity", Characteristic: "ampere"},
+	{CommandClass: 37, Property: "currentValue", MeasuringFunction: "getOnOffState", Aspect: "device", Characteristic: "boolean"},
+	{CommandClass: 37, Property: "targetValue", ControllingFunction: "setOnOffState", Aspect: "device", Characteristic: "boolean"},
+	{CommandClass: 38, Property: "currentValue", MeasuringFunction: "getLevel", Aspect: "device", Characteristic: "percentage"},
+	{CommandClass: 38, Property: "targetValue", ControllingFunction: "setLevel", Aspect: "device", Characteristic: "percentage"},
+	{CommandClass: 67, Property: "setpoint", Unit: "°C", MeasuringFunction: "getTargetTemperature", ControllingFunction: "setTargetTemperature", Aspect: "air", Characteristic: "celsius"},
+	{CommandClass: 128, Property: "level", MeasuringFunction: "getBatteryLevel", Aspect: "battery", Characteristic: "percentage"},
+}
+
+type valueSemantics struct {
+	FunctionId       string
+	AspectId         string
+	CharacteristicId string
+}
+
+// returns the semantics of the first matching rule with a resolvable function for the service direction
+func (this *Connector) getValueSemantics(value model.Value, get bool) (result valueSemantics, ok bool) {
+	for _, rule := range append(append([]configuration.SemanticRule{}, this.config.SemanticRules...), DefaultSemanticRules...) {
+		if !semanticRuleMatches(rule, value) {
+			continue
+		}
+		function := rule.ControllingFunction
+		if get {
+			function = rule.MeasuringFunction
+		}
+		result.FunctionId = this.resolveSemanticId(function)
+		if result.FunctionId == "" {
+			continue
+		}
+		result.AspectId = this.resolveSemanticId(rule.Aspect)
+		result.CharacteristicId = this.resolveSemanticId(rule.Characteristic)
+		return result, true
+	}
+	return result, false
+}
+
+func semanticRuleMatches(rule configuration.SemanticRule, value model.Value) bool {
+	if rule.CommandClass != 0 && rule.CommandClass != value.ClassId {
+		return false
+	}
+	if rule.Property != "" && (value.Property == nil || rule.Property != fmt.Sprint(value.Property)) {
+		return false
+	}
+	if rule.PropertyKey != "" && (value.PropertyKey == nil || rule.PropertyKey != fmt.Sprint(value.PropertyKey)) {
+		return false
+	}
+	if rule.Unit != "" && rule.Unit != value.Unit {
+		return false
+	}
+	return true
+}
+
+func (this *Connector) resolveSemanticId(name string) string {
+	if name == "" {
+		return ""
+	}
+	if id, ok := this.config.SemanticIds[name]; ok {
+		return id
+	}
+	if strings.HasPrefix(name, "urn:") {
+		return name
+	}
+	if this.devicerepo == nil {
+		return ""
+	}
+	ids, err := this.devicerepo.GetSemanticIds()
+	if err != nil {
+		if _, reported := this.unresolvedSemanticNames.LoadOrStore(err.Error(), true); !reported {
+			this.config.GetLogger().Warn("unable to load semantic ids from device repository", "error", err)
+		}
+		return ""
+	}
+	if id, ok := ids[devicerepo.NormalizeSemanticName(name)]; ok {
+		return id
+	}
+	if _, reported := this.unresolvedSemanticNames.LoadOrStore(name, true); !reported {
+		this.config.GetLogger().Warn("unable to resolve semantic name; set it in semantic_ids", "name", name)
+	}
+	return ""
+}
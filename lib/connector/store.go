@@ -0,0 +1,61 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"encoding/json"
+)
+
+// keys of the connector state in the fallback file
+const (
+	StoreKeyDeviceGroups = "device-groups"
+	StoreKeyDeviceNames  = "device-names"
+)
+
+// getStored decodes a value of the fallback file; values read from the file are generic json structures
+func getStored[T any](this *Connector, key string) (result T, ok bool) {
+	if this.store == nil {
+		return result, false
+	}
+	value, err := this.store.Get(key)
+	if err != nil {
+		return result, false
+	}
+	if result, ok = value.(T); ok {
+		return result, true
+	}
+	temp, err := json.Marshal(value)
+	if err == nil {
+		err = json.Unmarshal(temp, &result)
+	}
+	if err != nil {
+		this.config.GetLogger().Error("unexpected format of stored connector state", "key", key, "error", err)
+		return result, false
+	}
+	return result, true
+}
+
+func (this *Connector) setStored(key string, value interface{}) {
+	if this.store == nil {
+		return
+	}
+	err := this.store.Set(key, value)
+	if err != nil {
+		this.config.GetLogger().Error("unable to store connector state in fallback file", "key", key, "error", err)
+		this.mgwClient.SendClientError("unable to store connector state in fallback file: " + err.Error())
+	}
+}
@@ -0,0 +1,153 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package connector
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+const ControllerServiceTopology = "topology"
+const ControllerServiceRefreshTopology = "refreshTopology"
+
+const EdgeTypeNeighbor = "neighbor"
+const EdgeTypeRoute = "route"
+
+type Topology struct {
+	Nodes []TopologyNode `json:"nodes"`
+	Edges []TopologyEdge `json:"edges"`
+	Time  int64          `json:"time"`
+}
+
+type TopologyNode struct {
+	NodeId       int64    `json:"nodeId"`
+	DeviceId     string   `json:"deviceId"`
+	Name         string   `json:"name"`
+	IsController bool     `json:"isController"`
+	IsRouting    bool     `json:"isRouting"`
+	Rssi         *float64 `json:"rssi,omitempty"`
+}
+
+// TopologyEdge is a neighbor relation (undirected, listed once) or a hop of the last working route (directed towards the controller)
+type TopologyEdge struct {
+	Source int64    `json:"source"`
+	Target int64    `json:"target"`
+	Type   string   `json:"type"`
+	Rssi   *float64 `json:"rssi,omitempty"` //link quality of route hops in dBm, as received by the target
+}
+
+func (this *Connector) buildTopology(controller *Controller, nodes []model.DeviceInfo) (result Topology) {
+	result = Topology{Nodes: []TopologyNode{}, Edges: []TopologyEdge{}, Time: time.Now().UnixMilli()}
+	controllerNodeId := controller.getControllerNodeId()
+	knownNodes := map[int64]bool{}
+	addNode := func(node TopologyNode) {
+		if !knownNodes[node.NodeId] {
+			knownNodes[node.NodeId] = true
+			result.Nodes = append(result.Nodes, node)
+		}
+	}
+	addNode(TopologyNode{NodeId: controllerNodeId, DeviceId: controller.getControllerDeviceId(), Name: "controller", IsController: true, IsRouting: true})
+	for _, node := range nodes {
+		addNode(TopologyNode{
+			NodeId:    node.NodeId,
+			DeviceId:  controller.nodeIdToDeviceId(node.NodeId),
+			Name:      node.Name,
+			IsRouting: node.Topology.IsRouting,
+			Rssi:      node.Topology.Rssi,
+		})
+	}
+	neighborEdges := map[[2]int64]bool{}
+	for _, node := range nodes {
+		for _, neighbor := range node.Topology.Neighbors {
+			key := [2]int64{min(node.NodeId, neighbor), max(node.NodeId, neighbor)}
+			if !neighborEdges[key] {
+				neighborEdges[key] = true
+				result.Edges = append(result.Edges, TopologyEdge{Source: node.NodeId, Target: neighbor, Type: EdgeTypeNeighbor})
+			}
+		}
+		if route := node.Topology.LastWorkingRoute; route != nil {
+			hops := append(append([]int64{node.NodeId}, route.Repeaters...), controllerNodeId)
+			for i := 0; i < len(hops)-1; i++ {
+				edge := TopologyEdge{Source: hops[i], Target: hops[i+1], Type: EdgeTypeRoute}
+				if i < len(route.RepeaterRssi) {
+					rssi := route.RepeaterRssi[i]
+					edge.Rssi = &rssi
+				} else if i == len(hops)-2 {
+					edge.Rssi = route.Rssi
+				}
+				result.Edges = append(result.Edges, edge)
+			}
+		}
+	}
+	for _, edge := range result.Edges {
+		for _, id := range []int64{edge.Source, edge.Target} {
+			addNode(TopologyNode{NodeId: id, DeviceId: controller.nodeIdToDeviceId(id), IsController: id == controllerNodeId})
+		}
+	}
+	slices.SortFunc(result.Nodes, func(a, b TopologyNode) int {
+		return int(a.NodeId - b.NodeId)
+	})
+	return result
+}
+
+func (this *Connector) sendTopology(controller *Controller, nodes []model.DeviceInfo) {
+	deviceId := controller.getControllerDeviceId()
+	if _, ok := this.deviceRegisterGet(deviceId); !ok {
+		return
+	}
+	err := this.mgwClient.MarshalAndSendEvent(deviceId, ControllerServiceTopology, this.buildTopology(controller, nodes))
+	if err != nil {
+		this.config.GetLogger().Error("unable to send event", "device", deviceId, "service", ControllerServiceTopology, "error", err)
+		this.mgwClient.SendClientError("unable to send event: " + err.Error())
+	}
+}
+
+// lets the controller refresh the neighbor lists and requests the node infos, which results in a new topology event
+func (this *Connector) refreshTopology(controller *Controller) (result interface{}, err error) {
+	result, err = controller.client.RefreshNeighbors()
+	if err != nil {
+		return result, err
+	}
+	return result, controller.client.RequestDeviceInfoUpdate()
+}
+
+func (this *Connector) startTopologyRefresh(ctx context.Context) {
+	interval := this.config.TopologyRefreshInterval.GetDuration()
+	if interval == 0 || this.config.ControllerDeviceTypeId == "" || this.config.ControllerDeviceTypeId == "-" {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for _, controller := range this.controllers {
+					_, err := this.refreshTopology(controller)
+					if err != nil {
+						this.config.GetLogger().Warn("unable to refresh topology", "controller", controller.deviceIdPrefix, "error", err)
+					}
+				}
+			}
+		}
+	}()
+}
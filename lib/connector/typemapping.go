@@ -17,29 +17,56 @@
 package connector
 
 import (
+	"log/slog"
+	"reflect"
+	"slices"
+
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/mgw"
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
-	"strconv"
 )
 
 // result id with prefix
-func (this *Connector) nodeToDeviceInfo(node model.DeviceInfo) (id string, info mgw.DeviceInfo, err error) {
-	id = this.nodeIdToDeviceId(node.NodeId)
+func (this *Connector) nodeToDeviceInfo(controller *Controller, node model.DeviceInfo) (id string, info mgw.DeviceInfo, err error) {
+	id = controller.addDeviceIdPrefix(node.GetRawDeviceId())
 	info = mgw.DeviceInfo{
-		Name:  node.Name,
+		Name:  this.resolveDeviceName(controller, id, node),
 		State: mgw.Online,
 	}
-	if info.Name == "" {
-		info.Name = getDefaultName(node)
-	}
 	info.DeviceType, err = this.provideDeviceTypeId(node)
+	if err == nil && this.config.SyncDeviceTypes {
+		this.syncDeviceType(id, node, info.DeviceType)
+	}
 	return
 }
 
 func getDefaultName(node model.DeviceInfo) string {
-	return node.Product + " (" + strconv.FormatInt(node.NodeId, 10) + ")"
+	return node.Product + " (" + node.GetRawDeviceId() + ")"
 }
 
 func (this *Connector) getTypeMappingKey(node model.DeviceInfo) string {
-	return node.ManufacturerId + "." + node.ProductType + "." + node.ProductId
+	return node.GetCanonicalTypeMappingKey()
+}
+
+// returns the map with canonical mapping keys; on conflicting entries the first key (sorted) wins
+func canonicalizeMappingKeys[T any](m map[string]T, logger *slog.Logger) map[string]T {
+	if m == nil {
+		return nil
+	}
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	result := map[string]T{}
+	for _, key := range keys {
+		canonical := model.CanonicalTypeMappingKey(key)
+		if existing, ok := result[canonical]; ok {
+			if !reflect.DeepEqual(existing, m[key]) {
+				logger.Warn("ignore conflicting mapping key", "key", key, "canonical", canonical)
+			}
+			continue
+		}
+		result[canonical] = m[key]
+	}
+	return result
 }
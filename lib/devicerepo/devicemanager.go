@@ -24,22 +24,19 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
-	"time"
 
 	"github.com/SENERGY-Platform/models/go/models"
 )
 
 func (this *DeviceRepo) CreateDeviceTypeWithDistinctAttributes(key string, dt models.DeviceType, attributeKeys []string) (result models.DeviceType, code int, err error) {
-	this.dtMux.Lock()
-	defer this.dtMux.Unlock()
+	this.createMux.Lock()
+	defer this.createMux.Unlock()
 
 	//prevent creation of duplicate device-types
-	if existing, ok := this.createdDt[key]; ok {
+	if existing, ok := this.getCreatedDeviceType(key); ok {
 		return existing, 200, nil
 	}
 
-	this.lastDtRefresh = time.Time{} //force new http request on next FindDeviceTypeId() call
-
 	buf := &bytes.Buffer{}
 	err = json.NewEncoder(buf).Encode(dt)
 	if err != nil {
@@ -56,34 +53,72 @@ func (this *DeviceRepo) CreateDeviceTypeWithDistinctAttributes(key string, dt mo
 		}
 		req.Header.Set("Authorization", token)
 	}
-	result, code, err = Do[models.DeviceType](req)
+	//the post is not retried: a timed out request may have created the device type, a retry would fail the distinct attribute check
+	result, code, err = doOnce[models.DeviceType](this.client, req)
+	if err != nil && isRetryableStatus(code) {
+		existing, ok := this.lookupCreatedDeviceType(key)
+		if !ok {
+			return result, code, err
+		}
+		this.config.GetLogger().Warn("device type creation failed but device type exists", "mappingKey", key, "deviceType", existing.Id, "error", err)
+		result, code, err = existing, http.StatusOK, nil
+	}
 	if err != nil {
 		return result, code, err
 	}
 
-	//don't create this device type again
+	//don't create this device type again; FindDeviceTypeId() uses it until the device type list contains it
+	this.dtMux.Lock()
 	this.createdDt[key] = result
-
-	//ensure device type has time to be accessible before continuing
-	time.Sleep(1 * time.Second)
+	this.dtMux.Unlock()
+	this.requestRefresh()
 
 	return result, code, err
 }
 
+// refreshes the device type list to find a device type which may have been created by a failed request
+func (this *DeviceRepo) lookupCreatedDeviceType(key string) (result models.DeviceType, ok bool) {
+	err := this.refreshDeviceTypeList()
+	if err != nil {
+		return result, false
+	}
+	deviceTypes, _, usedFallback := this.getDeviceTypeListSnapshot()
+	if usedFallback {
+		return result, false
+	}
+	return getDeviceTypeByMappingKey(deviceTypes, key)
+}
+
+func (this *DeviceRepo) getCreatedDeviceType(key string) (result models.DeviceType, ok bool) {
+	this.dtMux.Lock()
+	defer this.dtMux.Unlock()
+	result, ok = this.createdDt[key]
+	return
+}
+
 func Do[T any](req *http.Request) (result T, code int, err error) {
-	resp, err := http.DefaultClient.Do(req)
+	return DoWithClient[T](http.DefaultClient, req)
+}
+
+func DoWithClient[T any](client *http.Client, req *http.Request) (result T, code int, err error) {
+	result, _, code, err = doWithClient[T](client, req)
+	return result, code, err
+}
+
+func doWithClient[T any](client *http.Client, req *http.Request) (result T, header http.Header, code int, err error) {
+	resp, err := client.Do(req)
 	if err != nil {
-		return result, http.StatusInternalServerError, err
+		return result, nil, http.StatusInternalServerError, err
 	}
 	defer resp.Body.Close()
 	if resp.StatusCode > 299 {
 		temp, _ := io.ReadAll(resp.Body) //read error response end ensure that resp.Body is read to EOF
-		return result, resp.StatusCode, errors.New(string(temp))
+		return result, resp.Header, resp.StatusCode, errors.New(string(temp))
 	}
 	err = json.NewDecoder(resp.Body).Decode(&result)
 	if err != nil {
 		_, _ = io.ReadAll(resp.Body) //ensure resp.Body is read to EOF
-		return result, http.StatusInternalServerError, err
+		return result, resp.Header, http.StatusInternalServerError, err
 	}
-	return result, resp.StatusCode, nil
+	return result, resp.Header, resp.StatusCode, nil
 }
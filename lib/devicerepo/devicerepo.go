@@ -18,12 +18,12 @@ package devicerepo
 
 import (
 	"fmt"
+	"github.com/SENERGY-Platform/device-repository/lib/client"
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/devicerepo/fallback"
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
 	"github.com/SENERGY-Platform/models/go/models"
 	"slices"
-	"strings"
 	"sync"
 	"time"
 )
@@ -31,24 +31,47 @@ import (
 type DeviceRepo struct {
 	config                    configuration.Config
 	auth                      Auth
+	client                    *resilientClient
 	fallback                  fallback.Fallback
 	deviceTypes               []models.DeviceType
 	minCacheDuration          time.Duration
 	maxCacheDuration          time.Duration
 	lastDtRefresh             time.Time
 	lastDtRefreshUsedFallback bool
-	dtMux                     sync.Mutex
+	dtMux                     sync.Mutex //guards the cached list, createdDt and fetchedDt; never held during requests
+	refreshMux                sync.Mutex
+	createMux                 sync.Mutex
+	refreshRequests           chan struct{}
+	refreshListener           func()
 	createdDt                 map[string]models.DeviceType
+	fetchedDt                 map[string]fetchedDeviceType //by device type id; device types not in the list, requested by GetDeviceType
+	lookupKeys                map[string]*mappingKeyLookup //by canonical mapping key; used with device_type_lookup_by_mapping_key
+	missingKeys               map[string]time.Time         //by canonical mapping key; time of the refresh requested for a key missing in the list
+	semanticIds               map[string]string            //by normalized name
+	lastSemanticIdsAttempt    time.Time
+	lastSemanticIdsErr        error
+	semanticMux               sync.Mutex
+	listDeviceTypePage        func(token string, options client.DeviceTypeListOptions) (list []models.DeviceType, total int64, code int, err error)
 }
 
 type Auth interface {
 	EnsureAccess(config configuration.Config) (token string, err error)
 }
 
-func New(config configuration.Config, auth Auth) (*DeviceRepo, error) {
-	minCacheDuration, err := time.ParseDuration(config.MinCacheDuration)
-	if err != nil {
-		return nil, err
+// MinCacheDurationLimit is the lower limit of min_cache_duration; shorter durations would request a refresh for each lookup of an unknown device
+const MinCacheDurationLimit = 10 * time.Second
+
+func New(config configuration.Config, auth Auth) (result *DeviceRepo, err error) {
+	minCacheDuration := MinCacheDurationLimit
+	if config.MinCacheDuration != "" {
+		minCacheDuration, err = time.ParseDuration(config.MinCacheDuration)
+		if err != nil {
+			return nil, err
+		}
+		if minCacheDuration < MinCacheDurationLimit {
+			config.GetLogger().Warn("min_cache_duration too short --> use limit", "min_cache_duration", config.MinCacheDuration, "limit", MinCacheDurationLimit.String())
+			minCacheDuration = MinCacheDurationLimit
+		}
 	}
 	maxCacheDuration, err := time.ParseDuration(config.MaxCacheDuration)
 	if err != nil {
@@ -58,14 +81,28 @@ func New(config configuration.Config, auth Auth) (*DeviceRepo, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &DeviceRepo{
+	if f.Degraded() {
+		config.GetLogger().Error("fallback file damaged --> start without fallback device types", "file", config.FallbackFile)
+	}
+	result = &DeviceRepo{
 		auth:             auth,
+		client:           newResilientClient(config),
 		config:           config,
 		fallback:         f,
 		minCacheDuration: minCacheDuration,
 		maxCacheDuration: maxCacheDuration,
 		createdDt:        map[string]models.DeviceType{},
-	}, nil
+		fetchedDt:        map[string]fetchedDeviceType{},
+		refreshRequests:  make(chan struct{}, 1),
+		lookupKeys:       map[string]*mappingKeyLookup{},
+	}
+	result.listDeviceTypePage = result.requestDeviceTypePage
+	return result, nil
+}
+
+// GetFallback returns the store of the fallback file; other components may persist their state under their own keys
+func (this *DeviceRepo) GetFallback() fallback.Fallback {
+	return this.fallback
 }
 
 func (this *DeviceRepo) getToken() (string, error) {
@@ -75,39 +112,61 @@ func (this *DeviceRepo) getToken() (string, error) {
 	return this.auth.EnsureAccess(this.config)
 }
 
+// FindDeviceTypeId uses the current device type list without waiting for the device repository;
+// returns ErrDeviceTypeListPending if the list has not been loaded yet, does not contain the lookup for the mapping key
+// (device_type_lookup_by_mapping_key) or if a refresh is needed to decide that no device type matches
 func (this *DeviceRepo) FindDeviceTypeId(device model.DeviceInfo) (dtId string, usedFallback bool, err error) {
-	deviceTypes, err := this.ListZwaveDeviceTypes()
-	if err != nil {
-		return "", this.getLastDtRefreshUsedFallback(), err
+	deviceTypes, lastRefresh, usedFallback := this.getDeviceTypeListSnapshot()
+	lookupPending := this.config.DeviceTypeLookupByMappingKey && !this.addLookupKey(device)
+	if lastRefresh.IsZero() || lookupPending {
+		this.requestRefresh()
+		return "", usedFallback, fmt.Errorf("%w: mapping-key=%v", ErrDeviceTypeListPending, device.GetTypeMappingKey())
 	}
-	deviceType, ok := this.getMatchingDeviceType(deviceTypes, device)
-	if !ok && time.Since(this.lastDtRefresh) > this.minCacheDuration {
-		err = this.refreshDeviceTypeList()
-		if err != nil {
-			return "", this.getLastDtRefreshUsedFallback(), err
-		}
-		deviceTypes, err = this.ListZwaveDeviceTypes()
-		if err != nil {
-			return "", this.getLastDtRefreshUsedFallback(), err
+	if deviceType, ok := this.getMatchingDeviceType(deviceTypes, device); ok {
+		return deviceType.Id, usedFallback, nil
+	}
+	if deviceType, ok := this.getCreatedDeviceType(device.GetCanonicalTypeMappingKey()); ok {
+		return deviceType.Id, usedFallback, nil
+	}
+	if this.requestRefreshForMissingKey(device.GetCanonicalTypeMappingKey(), lastRefresh) {
+		return "", usedFallback, fmt.Errorf("%w: mapping-key=%v", ErrDeviceTypeListPending, device.GetTypeMappingKey())
+	}
+	return "", usedFallback, fmt.Errorf("%w: mapping-key=%v", model.NoMatchingDeviceTypeFound, device.GetTypeMappingKey())
+}
+
+// returns true if a refresh for the missing key is pending; a key missing in the list refreshed after the request is not found
+// until min_cache_duration passed, so the registration of deferred devices does not request the next refresh
+func (this *DeviceRepo) requestRefreshForMissingKey(key string, lastRefresh time.Time) bool {
+	this.dtMux.Lock()
+	requested, ok := this.missingKeys[key]
+	outstanding := ok && lastRefresh.Before(requested)
+	refresh := !outstanding && time.Since(lastRefresh) > this.minCacheDuration && (!ok || time.Since(requested) > this.minCacheDuration)
+	if refresh {
+		if this.missingKeys == nil {
+			this.missingKeys = map[string]time.Time{}
 		}
-		deviceType, ok = this.getMatchingDeviceType(deviceTypes, device)
+		this.missingKeys[key] = time.Now()
 	}
-	if !ok {
-		return "", this.getLastDtRefreshUsedFallback(), fmt.Errorf("%w: mapping-key=%v", model.NoMatchingDeviceTypeFound, device.GetTypeMappingKey())
+	this.dtMux.Unlock()
+	if refresh {
+		this.requestRefresh()
 	}
-	return deviceType.Id, this.getLastDtRefreshUsedFallback(), nil
+	return outstanding || refresh
 }
 
 const AttributeZwaveTypeMappingKey = "senergy/zwave-type-mapping-key"
 
 func (this *DeviceRepo) getMatchingDeviceType(devicetypes []models.DeviceType, device model.DeviceInfo) (models.DeviceType, bool) {
-	deviceTypeKey := device.GetTypeMappingKey()
+	return getDeviceTypeByMappingKey(devicetypes, device.GetCanonicalTypeMappingKey())
+}
+
+func getDeviceTypeByMappingKey(devicetypes []models.DeviceType, deviceTypeKey string) (models.DeviceType, bool) {
 	for _, dt := range devicetypes {
 		attrMap := map[string][]string{}
 		for _, attr := range dt.Attributes {
-			attrMap[attr.Key] = append(attrMap[attr.Key], strings.TrimSpace(attr.Value))
+			attrMap[attr.Key] = append(attrMap[attr.Key], model.CanonicalTypeMappingKey(attr.Value))
 		}
-		if keys, keyIsSet := attrMap[AttributeZwaveTypeMappingKey]; keyIsSet && slices.Contains(keys, strings.TrimSpace(deviceTypeKey)) {
+		if keys, keyIsSet := attrMap[AttributeZwaveTypeMappingKey]; keyIsSet && slices.Contains(keys, deviceTypeKey) {
 			return dt, true
 		}
 	}
@@ -0,0 +1,372 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package devicerepo
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SENERGY-Platform/device-repository/lib/client"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+	"github.com/SENERGY-Platform/models/go/models"
+)
+
+func TestFindDeviceTypeIdDoesNotBlock(t *testing.T) {
+	repo := &DeviceRepo{
+		minCacheDuration: time.Minute,
+		maxCacheDuration: time.Hour,
+		createdDt:        map[string]models.DeviceType{},
+		refreshRequests:  make(chan struct{}, 1),
+	}
+	device := model.DeviceInfo{ManufacturerId: "0x010f", ProductType: "0x1201", ProductId: "0x1000"}
+
+	_, _, err := repo.FindDeviceTypeId(device)
+	if !errors.Is(err, ErrDeviceTypeListPending) || len(repo.refreshRequests) != 1 {
+		t.Error("expected pending refresh before the first load", err)
+	}
+
+	repo.deviceTypes = []models.DeviceType{{Id: "dt1", Attributes: []models.Attribute{{Key: AttributeZwaveTypeMappingKey, Value: "271.4609.4096"}}}}
+	repo.lastDtRefresh = time.Now()
+	<-repo.refreshRequests
+	if id, _, err := repo.FindDeviceTypeId(device); err != nil || id != "dt1" {
+		t.Error(id, err)
+	}
+
+	unknown := model.DeviceInfo{ManufacturerId: "1", ProductType: "2", ProductId: "3"}
+	if _, _, err = repo.FindDeviceTypeId(unknown); !errors.Is(err, model.NoMatchingDeviceTypeFound) || len(repo.refreshRequests) != 0 {
+		t.Error("expected no match for a recent list", err)
+	}
+
+	repo.createdDt["1.2.3"] = models.DeviceType{Id: "created"}
+	if id, _, err := repo.FindDeviceTypeId(unknown); err != nil || id != "created" {
+		t.Error(id, err)
+	}
+
+	repo.lastDtRefresh = time.Now().Add(-2 * time.Minute)
+	unknown.ProductId = "4"
+	if _, _, err = repo.FindDeviceTypeId(unknown); !errors.Is(err, ErrDeviceTypeListPending) || len(repo.refreshRequests) != 1 {
+		t.Error("expected pending refresh for an outdated list", err)
+	}
+	repo.requestRefresh() //must not block while a request is pending
+	if _, _, err = repo.FindDeviceTypeId(unknown); !errors.Is(err, ErrDeviceTypeListPending) || len(repo.refreshRequests) != 1 {
+		t.Error("expected pending lookup until the requested refresh is done", err)
+	}
+	<-repo.refreshRequests
+	repo.lastDtRefresh = time.Now()
+	if _, _, err = repo.FindDeviceTypeId(unknown); !errors.Is(err, model.NoMatchingDeviceTypeFound) || len(repo.refreshRequests) != 0 {
+		t.Error("expected no match after the requested refresh", err)
+	}
+
+	limited, err := New(configuration.Config{MinCacheDuration: "0s", MaxCacheDuration: "1h", FallbackFile: filepath.Join(t.TempDir(), "fallback.json")}, nil)
+	if err != nil || limited.minCacheDuration != MinCacheDurationLimit {
+		t.Error("expected min_cache_duration limit", err)
+	}
+}
+
+func TestResilientClient(t *testing.T) {
+	var failures atomic.Int64
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		if failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if string(body) != "payload" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`"ok"`))
+	}))
+	defer server.Close()
+
+	client := &resilientClient{
+		http:      server.Client(),
+		timeout:   time.Second,
+		retries:   2,
+		backoff:   time.Millisecond,
+		threshold: 3,
+		cooldown:  50 * time.Millisecond,
+		sleep:     func(time.Duration) {},
+		state:     CircuitClosed,
+	}
+	states := []CircuitState{}
+	client.setStateListener(func(state CircuitState) {
+		states = append(states, state)
+	})
+	post := func() (string, int, error) {
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("payload"))
+		return doResilient[string](client, req)
+	}
+
+	failures.Store(2)
+	if result, _, err := post(); err != nil || result != "ok" || requests.Load() != 3 {
+		t.Error("expected success after retries with rewound body", result, err, requests.Load())
+	}
+
+	failures.Store(100)
+	requests.Store(0)
+	if _, code, err := post(); err == nil || code != http.StatusServiceUnavailable || client.getState() != CircuitOpen {
+		t.Error("expected open circuit", code, err, client.getState())
+	}
+	if _, _, err := post(); !errors.Is(err, ErrCircuitOpen) || requests.Load() != 3 {
+		t.Error("expected no request while the circuit is open", err, requests.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	client.retries = -1
+	if _, _, err := post(); err == nil || client.getState() != CircuitOpen || requests.Load() != 4 {
+		t.Error("expected failed trial to reopen the circuit", err, client.getState(), requests.Load())
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	failures.Store(0)
+	if result, _, err := post(); err != nil || result != "ok" || client.getState() != CircuitClosed {
+		t.Error("expected successful trial to close the circuit", result, err, client.getState())
+	}
+
+	expected := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if !slices.Equal(states, expected) {
+		t.Error(states)
+	}
+}
+
+func TestDeviceTypeListPagination(t *testing.T) {
+	config := configuration.Config{
+		MinCacheDuration:         "1m",
+		MaxCacheDuration:         "1h",
+		FallbackFile:             filepath.Join(t.TempDir(), "fallback.json"),
+		DeviceRepositoryPageSize: 2,
+	}
+	repo, err := New(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored := []models.DeviceType{
+		{Id: "dt1", Attributes: []models.Attribute{{Key: AttributeZwaveTypeMappingKey, Value: "271.4609.4096"}}},
+		{Id: "dt2", Attributes: []models.Attribute{{Key: AttributeZwaveTypeMappingKey, Value: "0x0086.0x0002.0x0064"}}},
+		{Id: "dt3"},
+		{Id: "dt4"},
+		{Id: "dt5"},
+	}
+	requests := []client.DeviceTypeListOptions{}
+	repo.listDeviceTypePage = func(token string, options client.DeviceTypeListOptions) (list []models.DeviceType, total int64, code int, err error) {
+		requests = append(requests, options)
+		for _, dt := range stored {
+			if len(options.AttributeValues) > 0 && !slices.ContainsFunc(dt.Attributes, func(attr models.Attribute) bool {
+				return attr.Key == AttributeZwaveTypeMappingKey && slices.Contains(options.AttributeValues, attr.Value)
+			}) {
+				continue
+			}
+			list = append(list, dt)
+		}
+		total = int64(len(list))
+		list = list[min(options.Offset, total):min(options.Offset+options.Limit, total)]
+		return list, total, 200, nil
+	}
+
+	if err = repo.refreshDeviceTypeList(); err != nil {
+		t.Fatal(err)
+	}
+	if len(repo.deviceTypes) != 5 || len(requests) != 3 || requests[2].Offset != 4 || requests[2].Limit != 2 {
+		t.Error(len(repo.deviceTypes), requests)
+	}
+
+	repo.config.DeviceTypeLookupByMappingKey = true
+	requests = requests[:0]
+	device := model.DeviceInfo{ManufacturerId: "134", ProductType: "2", ProductId: "100"}
+	if _, _, err = repo.FindDeviceTypeId(device); !errors.Is(err, ErrDeviceTypeListPending) {
+		t.Error("expected pending lookup", err)
+	}
+	if err = repo.refreshDeviceTypeList(); err != nil {
+		t.Fatal(err)
+	}
+	if len(requests) != 1 || !slices.Contains(requests[0].AttributeValues, "0x0086.0x0002.0x0064") || len(repo.deviceTypes) != 1 {
+		t.Error(requests, repo.deviceTypes)
+	}
+	if id, _, err := repo.FindDeviceTypeId(device); err != nil || id != "dt2" {
+		t.Error(id, err)
+	}
+
+	stored = append(stored,
+		models.DeviceType{Id: "dt6", Attributes: []models.Attribute{{Key: AttributeZwaveTypeMappingKey, Value: "0x011D.0x1201.285-4096-4609"}}},
+		models.DeviceType{Id: "dt7", Attributes: []models.Attribute{{Key: AttributeZwaveTypeMappingKey, Value: "0x86.0x3.0x65.2"}}},
+	)
+	for _, e := range []struct {
+		device model.DeviceInfo
+		id     string
+	}{
+		{model.DeviceInfo{ManufacturerId: "285", ProductType: "4609", ProductId: "4096"}, "dt6"},
+		{model.DeviceInfo{ManufacturerId: "134", ProductType: "3", ProductId: "101", Endpoint: 2}, "dt7"},
+	} {
+		if _, _, err = repo.FindDeviceTypeId(e.device); !errors.Is(err, ErrDeviceTypeListPending) {
+			t.Error("expected pending lookup", err)
+		}
+		if err = repo.refreshDeviceTypeList(); err != nil {
+			t.Fatal(err)
+		}
+		if id, _, err := repo.FindDeviceTypeId(e.device); err != nil || id != e.id {
+			t.Error(e, id, err)
+		}
+	}
+}
+
+func TestSemanticIds(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Query().Get("offset") != "0" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		switch r.URL.Path {
+		case "/functions":
+			_, _ = w.Write([]byte(`[{"id":"f1","name":"Get Temperature"},{"id":"f2","name":"setOnOffStateFunction","display_name":"Set On-Off-State"}]`))
+		case "/aspects":
+			_, _ = w.Write([]byte(`[{"id":"a1","name":"Air"}]`))
+		case "/characteristics":
+			_, _ = w.Write([]byte(`[{"id":"c1","name":"Celsius"},{"id":"c2","name":"celsius"}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	repo, err := New(configuration.Config{
+		MinCacheDuration:    "1m",
+		MaxCacheDuration:    "1h",
+		FallbackFile:        filepath.Join(t.TempDir(), "fallback.json"),
+		DeviceRepositoryUrl: server.URL,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids, err := repo.GetSemanticIds()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, id := range map[string]string{"getTemperature": "f1", "setOnOffState": "f2", "air": "a1", "celsius": "c1"} {
+		if ids[NormalizeSemanticName(name)] != id {
+			t.Error(name, ids)
+		}
+	}
+	if _, err = repo.GetSemanticIds(); err != nil || requests.Load() != 3 {
+		t.Error("expected cached semantic ids", err, requests.Load())
+	}
+}
+
+func TestGetDeviceTypeCache(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		_, _ = w.Write([]byte(`{"id":"curated","name":"Fibaro Plug"}`))
+	}))
+	defer server.Close()
+	repo, err := New(configuration.Config{
+		MinCacheDuration:    "1m",
+		MaxCacheDuration:    "1h",
+		FallbackFile:        filepath.Join(t.TempDir(), "fallback.json"),
+		DeviceRepositoryUrl: server.URL,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.listDeviceTypePage = func(token string, options client.DeviceTypeListOptions) ([]models.DeviceType, int64, int, error) {
+		return nil, 0, 200, nil
+	}
+	for range 3 {
+		if dt, err := repo.GetDeviceType("curated"); err != nil || dt.Name != "Fibaro Plug" {
+			t.Error(dt, err)
+		}
+	}
+	if requests.Load() != 1 {
+		t.Error("expected cached device type", requests.Load())
+	}
+}
+
+func TestCreateDeviceTypeIsNotRetried(t *testing.T) {
+	var posts atomic.Int64
+	var created atomic.Bool
+	key := model.CanonicalTypeMappingKey("0x0086.0x0002.0x0064")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/device-types":
+			posts.Add(1)
+			w.WriteHeader(http.StatusGatewayTimeout)
+		case r.Method == http.MethodGet && r.URL.Path == "/v3/device-types":
+			if r.URL.Query().Get("attr-keys") != AttributeUsedForZwave || r.URL.Query().Get("limit") != "100" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Header().Set("X-Total-Count", "1")
+			if !created.Load() {
+				_, _ = w.Write([]byte(`[]`))
+				return
+			}
+			_, _ = w.Write([]byte(`[{"id":"dt1","attributes":[{"key":"` + AttributeZwaveTypeMappingKey + `","value":"0x0086.0x0002.0x0064"}]}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	repo, err := New(configuration.Config{
+		MinCacheDuration:    "1m",
+		MaxCacheDuration:    "1h",
+		FallbackFile:        filepath.Join(t.TempDir(), "fallback.json"),
+		DeviceRepositoryUrl: server.URL,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo.client.sleep = func(time.Duration) {}
+
+	if _, code, err := repo.CreateDeviceTypeWithDistinctAttributes(key, models.DeviceType{Name: "test"}, nil); err == nil || code != http.StatusGatewayTimeout || posts.Load() != 1 {
+		t.Error("expected single failed post", code, err, posts.Load())
+	}
+
+	created.Store(true)
+	dt, _, err := repo.CreateDeviceTypeWithDistinctAttributes(key, models.DeviceType{Name: "test"}, nil)
+	if err != nil || dt.Id != "dt1" || posts.Load() != 2 {
+		t.Error("expected device type found by lookup after failed post", dt, err, posts.Load())
+	}
+	if existing, ok := repo.getCreatedDeviceType(key); !ok || existing.Id != "dt1" {
+		t.Error(existing, ok)
+	}
+}
+
+func TestRetriesConfig(t *testing.T) {
+	config := configuration.Config{}
+	if client := newResilientClient(config); client.retries != DefaultRetries {
+		t.Error("expected default retries", client.retries)
+	}
+	if err := json.Unmarshal([]byte(`{"device_repository_retries": 0}`), &config); err != nil {
+		t.Fatal(err)
+	}
+	if client := newResilientClient(config); client.retries != 0 {
+		t.Error("expected disabled retries", client.retries)
+	}
+}
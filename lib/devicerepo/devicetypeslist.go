@@ -17,9 +17,14 @@
 package devicerepo
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/SENERGY-Platform/device-repository/lib/client"
@@ -29,64 +34,186 @@ import (
 const AttributeUsedForZwave = "senergy/zwave-dc"
 const DtFallbackKey = "device-types"
 
+// ErrDeviceTypeListPending is returned by lookups which need a device type list refresh; the refresh runs in the background
+var ErrDeviceTypeListPending = errors.New("device type list refresh pending")
+
+// ListZwaveDeviceTypes returns the current device type list and requests a background refresh if the list is outdated
 func (this *DeviceRepo) ListZwaveDeviceTypes() (list []models.DeviceType, err error) {
-	age := time.Since(this.lastDtRefresh)
-	if (this.lastDtRefreshUsedFallback && age > this.minCacheDuration) || age > this.maxCacheDuration {
-		err = this.refreshDeviceTypeList()
-		if err != nil {
-			return nil, err
-		}
+	list, _, _ = this.getDeviceTypeListSnapshot()
+	if this.deviceTypeListIsOutdated() {
+		this.requestRefresh()
 	}
-	return this.getDeviceTypeList(), nil
+	return list, nil
 }
 
-func (this *DeviceRepo) getLastDtRefreshUsedFallback() bool {
+func (this *DeviceRepo) deviceTypeListIsOutdated() bool {
+	_, lastRefresh, usedFallback := this.getDeviceTypeListSnapshot()
+	age := time.Since(lastRefresh)
+	return (usedFallback && age > this.minCacheDuration) || age > this.maxCacheDuration
+}
+
+// SetRefreshListener sets a function which is called after each device type list refresh
+func (this *DeviceRepo) SetRefreshListener(listener func()) {
 	this.dtMux.Lock()
 	defer this.dtMux.Unlock()
-	return this.lastDtRefreshUsedFallback
+	this.refreshListener = listener
+}
+
+// StartBackgroundRefresh loads the device type list and keeps it up to date (min_cache_duration, max_cache_duration);
+// lookups request additional refreshes
+func (this *DeviceRepo) StartBackgroundRefresh(ctx context.Context) {
+	interval := this.minCacheDuration
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		this.refreshDeviceTypeListAndLog()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if this.deviceTypeListIsOutdated() {
+					this.refreshDeviceTypeListAndLog()
+				}
+			case <-this.refreshRequests:
+				this.refreshDeviceTypeListAndLog()
+			}
+		}
+	}()
+}
+
+// does not block; requests while a refresh is pending are merged
+func (this *DeviceRepo) requestRefresh() {
+	select {
+	case this.refreshRequests <- struct{}{}:
+	default:
+	}
+}
+
+func (this *DeviceRepo) refreshDeviceTypeListAndLog() {
+	err := this.refreshDeviceTypeList()
+	if err != nil {
+		this.config.GetLogger().Error("unable to refresh device type list", "error", err)
+	}
 }
 
 func (this *DeviceRepo) refreshDeviceTypeList() error {
-	this.dtMux.Lock()
-	defer this.dtMux.Unlock()
-	result, err := this.getDeviceTypeListFromDeviceRepository()
-	if err == nil {
-		this.deviceTypes = result
-		this.lastDtRefresh = time.Now()
-		this.lastDtRefreshUsedFallback = false
-		err = this.fallback.Set(DtFallbackKey, this.deviceTypes)
+	this.refreshMux.Lock()
+	defer this.refreshMux.Unlock()
+	usedFallback := false
+	lookupKeys, lookupValues := this.getLookupKeys()
+	result, err := this.getDeviceTypeListFromDeviceRepository(lookupValues)
+	//without known nodes a lookup is empty; the fallback of the previous run is kept
+	keepFallback := this.config.DeviceTypeLookupByMappingKey && len(lookupKeys) == 0
+	if err == nil && !keepFallback {
+		err = this.fallback.Set(DtFallbackKey, result)
 		if err != nil {
 			this.config.GetLogger().Warn("unable to store device-types in fallback file", "error", err)
 		}
-		return nil
-	} else {
+	} else if err != nil {
 		this.config.GetLogger().Warn("unable to load device type list from device repository --> use fallback file to load device type list", "error", err)
 		result, err = this.getDeviceTypeListFromFallback()
 		if err != nil {
 			return err
 		}
-		this.deviceTypes = result
-		this.lastDtRefresh = time.Now()
-		this.lastDtRefreshUsedFallback = true
-		return nil
+		usedFallback = true
+	}
+	this.dtMux.Lock()
+	this.deviceTypes = result
+	this.lastDtRefresh = time.Now()
+	this.lastDtRefreshUsedFallback = usedFallback
+	this.markLookupKeysLoaded(lookupKeys)
+	listener := this.refreshListener
+	this.dtMux.Unlock()
+	if listener != nil {
+		listener()
 	}
+	return nil
 }
 
-func (this *DeviceRepo) getDeviceTypeListFromDeviceRepository() (result []models.DeviceType, err error) {
+// getDeviceTypeListFromDeviceRepository loads all z-wave device types or, with device_type_lookup_by_mapping_key, the device types with the lookup values
+func (this *DeviceRepo) getDeviceTypeListFromDeviceRepository(lookupValues []string) (result []models.DeviceType, err error) {
 	token, err := this.getToken()
 	if err != nil {
 		return result, err
 	}
-	result, _, err, _ = client.NewClient(this.config.DeviceRepositoryUrl, nil).ListDeviceTypesV3(token, client.DeviceTypeListOptions{
-		Limit:         9999,
-		Offset:        0,
+	if this.config.DeviceTypeLookupByMappingKey {
+		return this.lookupDeviceTypes(token, lookupValues)
+	}
+	return this.listDeviceTypes(token, client.DeviceTypeListOptions{
 		SortBy:        "name.asc",
 		AttributeKeys: []string{AttributeUsedForZwave},
 	})
+}
+
+// listDeviceTypes requests the device types page by page (device_repository_page_size)
+func (this *DeviceRepo) listDeviceTypes(token string, options client.DeviceTypeListOptions) (result []models.DeviceType, err error) {
+	options.Limit = int64(this.config.DeviceRepositoryPageSize)
+	if options.Limit <= 0 {
+		options.Limit = 100
+	}
+	result = []models.DeviceType{}
+	for {
+		list, total, _, err := this.listDeviceTypePage(token, options)
+		if err != nil {
+			return result, err
+		}
+		result = appendUnknownDeviceTypes(result, list)
+		options.Offset += int64(len(list))
+		if int64(len(list)) < options.Limit || (total > 0 && options.Offset >= total) {
+			return result, nil
+		}
+	}
+}
+
+// requestDeviceTypePage sends the request of client.ListDeviceTypesV3 with the resilient client;
+// the client library uses http.DefaultClient without timeout
+func (this *DeviceRepo) requestDeviceTypePage(token string, options client.DeviceTypeListOptions) (list []models.DeviceType, total int64, code int, err error) {
+	query := url.Values{}
+	query.Set("limit", strconv.FormatInt(options.Limit, 10))
+	query.Set("offset", strconv.FormatInt(options.Offset, 10))
+	for key, value := range map[string]string{
+		"sort":        options.SortBy,
+		"search":      options.Search,
+		"ids":         strings.Join(options.Ids, ","),
+		"attr-keys":   strings.Join(options.AttributeKeys, ","),
+		"attr-values": strings.Join(options.AttributeValues, ","),
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	req, err := http.NewRequest(http.MethodGet, this.config.DeviceRepositoryUrl+"/v3/device-types?"+query.Encode(), nil)
 	if err != nil {
-		return result, err
+		return nil, 0, http.StatusInternalServerError, err
 	}
-	return result, nil
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	list, header, code, err := doResilientWithHeader[[]models.DeviceType](this.client, req)
+	if err != nil {
+		return nil, 0, code, err
+	}
+	total, _ = strconv.ParseInt(header.Get("X-Total-Count"), 10, 64)
+	return list, total, code, nil
+}
+
+// pages may overlap if device types are created or deleted while listing
+func appendUnknownDeviceTypes(list []models.DeviceType, add []models.DeviceType) []models.DeviceType {
+	known := map[string]bool{}
+	for _, dt := range list {
+		known[dt.Id] = true
+	}
+	for _, dt := range add {
+		if !known[dt.Id] {
+			known[dt.Id] = true
+			list = append(list, dt)
+		}
+	}
+	return list
 }
 
 func (this *DeviceRepo) getDeviceTypeListFromFallback() (result []models.DeviceType, err error) {
@@ -118,8 +245,8 @@ func jsonCast(in interface{}, out interface{}) error {
 	return err
 }
 
-func (this *DeviceRepo) getDeviceTypeList() []models.DeviceType {
+func (this *DeviceRepo) getDeviceTypeListSnapshot() (list []models.DeviceType, lastRefresh time.Time, usedFallback bool) {
 	this.dtMux.Lock()
 	defer this.dtMux.Unlock()
-	return this.deviceTypes
+	return this.deviceTypes, this.lastDtRefresh, this.lastDtRefreshUsedFallback
 }
@@ -0,0 +1,120 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package devicerepo
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/SENERGY-Platform/models/go/models"
+)
+
+type fetchedDeviceType struct {
+	deviceType models.DeviceType
+	time       time.Time
+}
+
+// GetDeviceType uses the cached device type list, created device types and device types fetched within max_cache_duration
+// before requesting the device repository
+func (this *DeviceRepo) GetDeviceType(id string) (result models.DeviceType, err error) {
+	list, err := this.ListZwaveDeviceTypes()
+	if err == nil {
+		for _, dt := range list {
+			if dt.Id == id {
+				return dt, nil
+			}
+		}
+	}
+	this.dtMux.Lock()
+	for _, dt := range this.createdDt {
+		if dt.Id == id {
+			this.dtMux.Unlock()
+			return dt, nil
+		}
+	}
+	if fetched, ok := this.fetchedDt[id]; ok && time.Since(fetched.time) < this.maxCacheDuration {
+		this.dtMux.Unlock()
+		return fetched.deviceType, nil
+	}
+	this.dtMux.Unlock()
+	req, err := http.NewRequest(http.MethodGet, this.config.DeviceRepositoryUrl+"/device-types/"+url.PathEscape(id), nil)
+	if err != nil {
+		return result, err
+	}
+	if this.config.AuthEnabled() {
+		token, err := this.getToken()
+		if err != nil {
+			return result, err
+		}
+		req.Header.Set("Authorization", token)
+	}
+	result, _, err = doResilient[models.DeviceType](this.client, req)
+	if err != nil {
+		return result, err
+	}
+	this.dtMux.Lock()
+	defer this.dtMux.Unlock()
+	this.fetchedDt[id] = fetchedDeviceType{deviceType: result, time: time.Now()}
+	return result, nil
+}
+
+func (this *DeviceRepo) UpdateDeviceType(dt models.DeviceType, attributeKeys []string) (result models.DeviceType, code int, err error) {
+	buf := &bytes.Buffer{}
+	err = json.NewEncoder(buf).Encode(dt)
+	if err != nil {
+		return result, 500, err
+	}
+	req, err := http.NewRequest(http.MethodPut, this.config.DeviceRepositoryUrl+"/device-types/"+url.PathEscape(dt.Id)+"?distinct_attributes="+url.QueryEscape(strings.Join(attributeKeys, ",")), buf)
+	if err != nil {
+		return result, 500, err
+	}
+	if this.config.AuthEnabled() {
+		token, err := this.getToken()
+		if err != nil {
+			return result, 500, err
+		}
+		req.Header.Set("Authorization", token)
+	}
+	result, code, err = doResilient[models.DeviceType](this.client, req)
+	if err != nil {
+		return result, code, err
+	}
+
+	//keep the cache consistent until the next refresh; callers may still use the previous list
+	this.dtMux.Lock()
+	defer this.dtMux.Unlock()
+	this.deviceTypes = slices.Clone(this.deviceTypes)
+	for i, existing := range this.deviceTypes {
+		if existing.Id == result.Id {
+			this.deviceTypes[i] = result
+		}
+	}
+	for key, existing := range this.createdDt {
+		if existing.Id == result.Id {
+			this.createdDt[key] = result
+		}
+	}
+	if _, ok := this.fetchedDt[result.Id]; ok {
+		this.fetchedDt[result.Id] = fetchedDeviceType{deviceType: result, time: time.Now()}
+	}
+	return result, code, nil
+}
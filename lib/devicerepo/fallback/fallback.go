@@ -17,18 +17,30 @@
 package fallback
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
+	"time"
 )
 
+// SchemaVersion of the fallback file; files without version (plain maps) are read as version 0
+const SchemaVersion = 1
+
 type Fallback interface {
 	Get(key string) (value interface{}, err error)
 	Set(key string, value interface{}) (err error)
 }
 
+// NewFallback loads the fallback file or, if it is damaged, its backup (<file>.bak).
+// If neither can be read the fallback starts empty (degraded) and the damaged file is kept as <file>.corrupt-<unix time>.
 func NewFallback(file string) (result *FallbackImpl, err error) {
 	result = &FallbackImpl{
 		file: file,
@@ -38,9 +50,16 @@ func NewFallback(file string) (result *FallbackImpl, err error) {
 }
 
 type FallbackImpl struct {
-	file   string
-	memory map[string]interface{}
-	mux    sync.Mutex
+	file     string
+	memory   map[string]interface{}
+	degraded bool
+	mux      sync.Mutex
+}
+
+type fileContent struct {
+	Version  int             `json:"version"`
+	Checksum string          `json:"checksum"` //sha256 of the compact json data
+	Data     json.RawMessage `json:"data"`
 }
 
 func (this *FallbackImpl) Get(key string) (value interface{}, err error) {
@@ -63,11 +82,27 @@ func (this *FallbackImpl) Set(key string, value interface{}) (err error) {
 		this.memory = map[string]interface{}{}
 	}
 	this.memory[key] = value
-	file, err := json.MarshalIndent(this.memory, "", "    ")
+	data, err := json.Marshal(this.memory)
+	if err != nil {
+		return err
+	}
+	file, err := json.MarshalIndent(fileContent{Version: SchemaVersion, Checksum: checksum(data), Data: data}, "", "    ")
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(this.file, file)
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(this.file, file, 0644)
+	this.degraded = false
+	return nil
+}
+
+// Degraded is true if the fallback file and its backup could not be read at startup and nothing has been stored since
+func (this *FallbackImpl) Degraded() bool {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.degraded
 }
 
 func (this *FallbackImpl) loadState() (err error) {
@@ -78,24 +113,135 @@ func (this *FallbackImpl) loadState() (err error) {
 	if err != nil {
 		return err
 	}
-	if !exists {
+	backupExists, err := fileExists(backupFile(this.file))
+	if err != nil {
+		return err
+	}
+	if !exists && !backupExists {
 		slog.Info("fall back file does not exist --> a new one will be created at", "file", this.file)
 		this.memory = map[string]interface{}{}
 		return
 	}
-	temp, err := os.ReadFile(this.file)
+	var loadErr error
+	if exists {
+		this.memory, loadErr = readFile(this.file)
+		if loadErr == nil {
+			return nil
+		}
+		slog.Warn("unable to read fallback file --> try backup", "file", this.file, "error", loadErr)
+		keepDamagedFile(this.file) //prevents the next Set from replacing the backup with the damaged file
+	}
+	if backupExists {
+		var backupErr error
+		this.memory, backupErr = readFile(backupFile(this.file))
+		if backupErr == nil {
+			slog.Warn("fallback loaded from backup", "file", backupFile(this.file))
+			return nil
+		}
+		loadErr = errors.Join(loadErr, backupErr)
+	}
+	slog.Error("unable to read fallback file and backup --> start with empty fallback", "file", this.file, "error", loadErr)
+	this.memory = map[string]interface{}{}
+	this.degraded = true
+	return nil
+}
+
+func keepDamagedFile(file string) {
+	damaged := file + ".corrupt-" + strconv.FormatInt(time.Now().Unix(), 10)
+	err := os.Rename(file, damaged)
+	if err != nil {
+		slog.Warn("unable to keep damaged fallback file", "file", file, "error", err)
+	}
+}
+
+func readFile(file string) (state map[string]interface{}, err error) {
+	temp, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	raw := map[string]json.RawMessage{}
+	err = json.Unmarshal(temp, &raw)
+	if err != nil {
+		return nil, err
+	}
+	_, versioned := raw["version"]
+	_, hasData := raw["data"]
+	if !versioned || !hasData {
+		//unversioned file of previous releases
+		state = map[string]interface{}{}
+		err = json.Unmarshal(temp, &state)
+		return state, err
+	}
+	content := fileContent{}
+	err = json.Unmarshal(temp, &content)
+	if err != nil {
+		return nil, err
+	}
+	if content.Version > SchemaVersion {
+		return nil, fmt.Errorf("unsupported fallback schema version %v", content.Version)
+	}
+	data := &bytes.Buffer{}
+	err = json.Compact(data, content.Data)
+	if err != nil {
+		return nil, err
+	}
+	if checksum(data.Bytes()) != content.Checksum {
+		return nil, errors.New("fallback checksum mismatch")
+	}
+	state = map[string]interface{}{}
+	err = json.Unmarshal(data.Bytes(), &state)
+	return state, err
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func backupFile(file string) string {
+	return file + ".bak"
+}
+
+// writes to a temp file, syncs it and replaces the file; the previous file is kept as backup
+func writeFileAtomic(file string, content []byte) error {
+	temp := file + ".tmp"
+	f, err := os.OpenFile(temp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(content)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
+		_ = os.Remove(temp)
 		return err
 	}
-	state := map[string]interface{}{}
-	err = json.Unmarshal(temp, &state)
+	err = os.Rename(file, backupFile(file))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	err = os.Rename(temp, file)
 	if err != nil {
 		return err
 	}
-	this.memory = state
+	syncDir(filepath.Dir(file))
 	return nil
 }
 
+// persists the renames; not supported on every platform
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync()
+}
+
 func fileExists(filename string) (bool, error) {
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
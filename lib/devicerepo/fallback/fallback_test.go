@@ -19,8 +19,10 @@ package fallback
 import (
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -35,6 +37,7 @@ func TestFallback(t *testing.T) {
 			log.Println(err)
 			t.Error(err)
 		}
+		_ = os.Remove(filename + ".bak")
 	}()
 
 	var fallback Fallback
@@ -91,3 +94,77 @@ func testCreateFallback(f *Fallback, filename string) func(t *testing.T) {
 		*f = temp
 	}
 }
+
+func TestFallbackRecovery(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "fallback.json")
+
+	t.Run("legacy file", func(t *testing.T) {
+		err := os.WriteFile(filename, []byte(`{"foo": "bar"}`), 0644)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		var fallback Fallback
+		t.Run("init", testCreateFallback(&fallback, filename))
+		t.Run("check legacy value", testCheckFallbackValue(fallback, "foo", "bar"))
+		t.Run("set value", testSetFallbackValue(fallback, "foo", "batz"))
+		content, err := os.ReadFile(filename)
+		if err != nil || !strings.Contains(string(content), `"version": 1`) || !strings.Contains(string(content), `"checksum"`) {
+			t.Error(string(content), err)
+		}
+	})
+
+	t.Run("truncated file", func(t *testing.T) {
+		content, err := os.ReadFile(filename)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = os.WriteFile(filename, content[:len(content)/2], 0644)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		fallback, err := NewFallback(filename)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if fallback.Degraded() {
+			t.Error("expected recovery from backup")
+		}
+		t.Run("check backup value", testCheckFallbackValue(fallback, "foo", "bar"))
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		err := os.WriteFile(filename, []byte(`{"version": 1, "checksum": "x", "data": {"foo": "changed"}}`), 0644)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		err = os.WriteFile(filename+".bak", []byte(`{"foo": `), 0644)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		fallback, err := NewFallback(filename)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !fallback.Degraded() {
+			t.Error("expected degraded fallback")
+		}
+		if _, err = fallback.Get("foo"); err == nil {
+			t.Error("expected empty fallback")
+		}
+		t.Run("set value", testSetFallbackValue(fallback, "foo", "new"))
+		if fallback.Degraded() {
+			t.Error("fallback should not be degraded after set")
+		}
+		damaged, _ := filepath.Glob(filename + ".corrupt-*")
+		if len(damaged) != 1 {
+			t.Error("damaged file should be kept", damaged)
+		}
+	})
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package devicerepo
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/SENERGY-Platform/device-repository/lib/client"
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+	"github.com/SENERGY-Platform/models/go/models"
+)
+
+// the attribute values of one list request are part of its url
+const lookupValuesPerRequest = 50
+
+type mappingKeyLookup struct {
+	values []string //forms of the mapping key which may be stored in the device repository
+	loaded bool     //included in a completed refresh
+}
+
+// addLookupKey remembers the mapping key of the device for the following refreshes; returns true if the current list contains its lookup
+func (this *DeviceRepo) addLookupKey(device model.DeviceInfo) (loaded bool) {
+	key := device.GetCanonicalTypeMappingKey()
+	this.dtMux.Lock()
+	defer this.dtMux.Unlock()
+	if lookup, ok := this.lookupKeys[key]; ok {
+		return lookup.loaded
+	}
+	this.lookupKeys[key] = &mappingKeyLookup{values: getLookupValues(device)}
+	return false
+}
+
+// attribute values are compared exactly by the device repository; device types may use every key form accepted by
+// model.CanonicalTypeMappingKey: decimal, hex (padded or not, lower or upper case) and zwave2mqtt device ids as product id
+func getLookupValues(device model.DeviceInfo) (result []string) {
+	result = []string{device.GetCanonicalTypeMappingKey(), device.GetTypeMappingKey()}
+	manufacturer, manufacturerErr := model.ParseTypeMappingKeyPart(device.ManufacturerId)
+	productType, productTypeErr := model.ParseTypeMappingKeyPart(device.ProductType)
+	productId, productIdErr := model.ParseTypeMappingKeyPart(device.ProductId)
+	if manufacturerErr == nil && productTypeErr == nil && productIdErr == nil {
+		suffix := ""
+		if device.Endpoint > 0 {
+			suffix = fmt.Sprintf(".%v", device.Endpoint)
+		}
+		zwave2mqttDeviceId := fmt.Sprintf("%d-%d-%d", manufacturer, productId, productType)
+		for _, format := range []string{"%d", "0x%04x", "0x%04X", "0x%x", "0x%X"} {
+			prefix := fmt.Sprintf(format+"."+format+".", manufacturer, productType)
+			result = append(result, prefix+fmt.Sprintf(format, productId)+suffix, prefix+zwave2mqttDeviceId+suffix)
+		}
+	}
+	slices.Sort(result)
+	return slices.Compact(result)
+}
+
+func (this *DeviceRepo) getLookupKeys() (keys []string, values []string) {
+	this.dtMux.Lock()
+	defer this.dtMux.Unlock()
+	for _, key := range slices.Sorted(maps.Keys(this.lookupKeys)) {
+		keys = append(keys, key)
+		values = append(values, this.lookupKeys[key].values...)
+	}
+	return keys, values
+}
+
+// expects a locked dtMux
+func (this *DeviceRepo) markLookupKeysLoaded(keys []string) {
+	for _, key := range keys {
+		if lookup, ok := this.lookupKeys[key]; ok {
+			lookup.loaded = true
+		}
+	}
+}
+
+// lookupDeviceTypes loads the device types whose mapping key attribute has one of the values
+func (this *DeviceRepo) lookupDeviceTypes(token string, values []string) (result []models.DeviceType, err error) {
+	result = []models.DeviceType{}
+	for chunk := range slices.Chunk(values, lookupValuesPerRequest) {
+		list, err := this.listDeviceTypes(token, client.DeviceTypeListOptions{
+			SortBy:          "name.asc",
+			AttributeKeys:   []string{AttributeZwaveTypeMappingKey},
+			AttributeValues: chunk,
+		})
+		if err != nil {
+			return result, err
+		}
+		result = appendUnknownDeviceTypes(result, list)
+	}
+	return result, nil
+}
@@ -0,0 +1,239 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package devicerepo
+
+import (
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
+)
+
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"    //requests pass
+	CircuitOpen     CircuitState = "open"      //requests fail with ErrCircuitOpen until the cooldown is over
+	CircuitHalfOpen CircuitState = "half-open" //one trial request passes; its result closes or reopens the circuit
+)
+
+// ErrCircuitOpen is returned without a request while the device repository is considered unreachable
+var ErrCircuitOpen = errors.New("device repository unavailable: circuit breaker open")
+
+const maxRetryBackoff = time.Minute
+
+// DefaultRetries is used if device_repository_retries is unset; 0 disables retries
+const DefaultRetries = 3
+
+// resilientClient sends device repository requests with timeout, retries and circuit breaker
+type resilientClient struct {
+	http      *http.Client
+	timeout   time.Duration
+	retries   int
+	backoff   time.Duration
+	threshold int
+	cooldown  time.Duration
+	sleep     func(time.Duration)
+
+	mux           sync.Mutex
+	state         CircuitState
+	failures      int
+	openedAt      time.Time
+	trialRunning  bool
+	stateListener func(state CircuitState)
+}
+
+func newResilientClient(config configuration.Config) *resilientClient {
+	result := &resilientClient{
+		timeout:   config.DeviceRepositoryTimeout.GetDuration(),
+		retries:   config.DeviceRepositoryRetries.Get(DefaultRetries),
+		backoff:   config.DeviceRepositoryRetryBackoff.GetDuration(),
+		threshold: config.DeviceRepositoryBreakerThreshold,
+		cooldown:  config.DeviceRepositoryBreakerCooldown.GetDuration(),
+		sleep:     time.Sleep,
+		state:     CircuitClosed,
+	}
+	if result.timeout <= 0 {
+		result.timeout = 30 * time.Second
+	}
+	if result.backoff <= 0 {
+		result.backoff = time.Second
+	}
+	if result.threshold <= 0 {
+		result.threshold = 5
+	}
+	if result.cooldown <= 0 {
+		result.cooldown = time.Minute
+	}
+	result.http = &http.Client{Timeout: result.timeout}
+	return result
+}
+
+// call runs request until it succeeds, fails with a non-retryable status code or the retries are used up;
+// request returns the http status code of its response
+func (this *resilientClient) call(request func() (code int, err error)) (code int, err error) {
+	return this.callWithRetries(this.retries, request)
+}
+
+// callOnce runs request once; the result is still counted by the circuit breaker
+func (this *resilientClient) callOnce(request func() (code int, err error)) (code int, err error) {
+	return this.callWithRetries(0, request)
+}
+
+func (this *resilientClient) callWithRetries(retries int, request func() (code int, err error)) (code int, err error) {
+	for attempt := 0; ; attempt++ {
+		err = this.acquire()
+		if err != nil {
+			return http.StatusServiceUnavailable, err
+		}
+		code, err = request()
+		retryable := err != nil && isRetryableStatus(code)
+		this.release(!retryable) //a non-retryable error response still proves that the repository is reachable
+		if !retryable || attempt >= retries {
+			return code, err
+		}
+		this.sleep(this.backoffDuration(attempt))
+	}
+}
+
+func isRetryableStatus(code int) bool {
+	return code == 0 || code == http.StatusTooManyRequests || code >= 500
+}
+
+// doubles the wait time per attempt and picks a random duration in the upper half to spread the retries of several connectors
+func (this *resilientClient) backoffDuration(attempt int) time.Duration {
+	wait := maxRetryBackoff
+	if attempt < 16 && this.backoff<<attempt < maxRetryBackoff {
+		wait = this.backoff << attempt
+	}
+	return wait/2 + rand.N(wait/2+1)
+}
+
+func (this *resilientClient) acquire() error {
+	this.mux.Lock()
+	switch this.state {
+	case CircuitOpen:
+		if time.Since(this.openedAt) < this.cooldown {
+			this.mux.Unlock()
+			return ErrCircuitOpen
+		}
+		this.trialRunning = true
+		this.setState(CircuitHalfOpen) //unlocks
+		return nil
+	case CircuitHalfOpen:
+		defer this.mux.Unlock()
+		if this.trialRunning {
+			return ErrCircuitOpen
+		}
+		this.trialRunning = true
+		return nil
+	default:
+		this.mux.Unlock()
+		return nil
+	}
+}
+
+func (this *resilientClient) release(success bool) {
+	this.mux.Lock()
+	this.trialRunning = false
+	if success {
+		this.failures = 0
+		if this.state != CircuitClosed {
+			this.setState(CircuitClosed) //unlocks
+			return
+		}
+		this.mux.Unlock()
+		return
+	}
+	this.failures++
+	if this.state == CircuitHalfOpen || (this.state == CircuitClosed && this.failures >= this.threshold) {
+		this.openedAt = time.Now()
+		this.setState(CircuitOpen) //unlocks
+		return
+	}
+	this.mux.Unlock()
+}
+
+// expects a locked mux; unlocks it before the listener is called
+func (this *resilientClient) setState(state CircuitState) {
+	this.state = state
+	listener := this.stateListener
+	this.mux.Unlock()
+	if listener != nil {
+		listener(state)
+	}
+}
+
+func (this *resilientClient) getState() CircuitState {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	return this.state
+}
+
+func (this *resilientClient) setStateListener(listener func(state CircuitState)) {
+	this.mux.Lock()
+	defer this.mux.Unlock()
+	this.stateListener = listener
+}
+
+// doResilient sends req with retries; the request body is rewound for each attempt
+func doResilient[T any](client *resilientClient, req *http.Request) (result T, code int, err error) {
+	result, _, code, err = doResilientWithHeader[T](client, req)
+	return result, code, err
+}
+
+// doResilientWithHeader is doResilient returning the response header of the last attempt
+func doResilientWithHeader[T any](client *resilientClient, req *http.Request) (result T, header http.Header, code int, err error) {
+	code, err = client.call(func() (int, error) {
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return http.StatusInternalServerError, err
+			}
+			req.Body = body
+		}
+		var code int
+		var err error
+		result, header, code, err = doWithClient[T](client.http, req)
+		return code, err
+	})
+	return result, header, code, err
+}
+
+// doOnce sends req without retries; used for requests which are not idempotent
+func doOnce[T any](client *resilientClient, req *http.Request) (result T, code int, err error) {
+	code, err = client.callOnce(func() (int, error) {
+		var code int
+		var err error
+		result, _, code, err = doWithClient[T](client.http, req)
+		return code, err
+	})
+	return result, code, err
+}
+
+// CircuitState returns the state of the device repository circuit breaker
+func (this *DeviceRepo) CircuitState() CircuitState {
+	return this.client.getState()
+}
+
+// SetCircuitStateListener sets a function which is called on each change of the device repository circuit breaker state
+func (this *DeviceRepo) SetCircuitStateListener(listener func(state CircuitState)) {
+	this.client.setStateListener(listener)
+}
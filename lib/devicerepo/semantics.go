@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package devicerepo
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/SENERGY-Platform/models/go/models"
+)
+
+const semanticListLimit = 500
+
+// NormalizeSemanticName makes names like "getTemperature" and "Get Temperature" comparable
+func NormalizeSemanticName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// GetSemanticIds returns the ids of the functions, aspects and characteristics of the device repository by normalized name;
+// the result is loaded once, failed loads are retried after min_cache_duration
+func (this *DeviceRepo) GetSemanticIds() (result map[string]string, err error) {
+	this.semanticMux.Lock()
+	defer this.semanticMux.Unlock()
+	if this.semanticIds != nil {
+		return this.semanticIds, nil
+	}
+	if !this.lastSemanticIdsAttempt.IsZero() && time.Since(this.lastSemanticIdsAttempt) < this.minCacheDuration {
+		return nil, this.lastSemanticIdsErr
+	}
+	this.lastSemanticIdsAttempt = time.Now()
+	result, err = this.loadSemanticIds()
+	if err != nil {
+		this.lastSemanticIdsErr = err
+		return nil, err
+	}
+	this.semanticIds = result
+	return result, nil
+}
+
+func (this *DeviceRepo) loadSemanticIds() (result map[string]string, err error) {
+	result = map[string]string{}
+	functions, err := listSemanticEntities[models.Function](this, "/functions")
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range functions {
+		addSemanticId(result, f.Id, f.Name, f.DisplayName)
+	}
+	aspects, err := listSemanticEntities[models.Aspect](this, "/aspects")
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range aspects {
+		addSemanticId(result, a.Id, a.Name)
+	}
+	characteristics, err := listSemanticEntities[models.Characteristic](this, "/characteristics")
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range characteristics {
+		addSemanticId(result, c.Id, c.Name)
+	}
+	return result, nil
+}
+
+// the first entity wins if names collide
+func addSemanticId(ids map[string]string, id string, names ...string) {
+	for _, name := range names {
+		key := NormalizeSemanticName(name)
+		if _, exists := ids[key]; key != "" && !exists {
+			ids[key] = id
+		}
+	}
+}
+
+func listSemanticEntities[T any](this *DeviceRepo, path string) (result []T, err error) {
+	for offset := 0; ; offset += semanticListLimit {
+		query := url.Values{}
+		query.Set("limit", strconv.Itoa(semanticListLimit))
+		query.Set("offset", strconv.Itoa(offset))
+		req, err := http.NewRequest(http.MethodGet, this.config.DeviceRepositoryUrl+path+"?"+query.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		if this.config.AuthEnabled() {
+			token, err := this.getToken()
+			if err != nil {
+				return nil, err
+			}
+			req.Header.Set("Authorization", token)
+		}
+		page, _, err := doResilient[[]T](this.client, req)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, page...)
+		if len(page) < semanticListLimit {
+			return result, nil
+		}
+	}
+}
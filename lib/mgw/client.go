@@ -85,6 +85,17 @@ func New(config configuration.Config, ctx context.Context, refreshNotifier func(
 	return client, nil
 }
 
+// NewWithMqttClient creates a client for an existing mqtt client (e.g. a fake without broker in tests);
+// subscriptions are not restored on reconnect
+func NewWithMqttClient(config configuration.Config, mqtt paho.Client) *Client {
+	return &Client{
+		mqtt:          mqtt,
+		connectorId:   config.ConnectorId,
+		debug:         config.Debug,
+		subscriptions: map[string]paho.MessageHandler{},
+	}
+}
+
 func (this *Client) NotifyDeviceManagerRefresh(f func()) {
 	this.deviceManagerRefreshNotifier = f
 }
@@ -22,3 +22,4 @@ var ErrNotFound = errors.New("not found")
 var ErrNoValueFound = errors.New("no value found")
 var ErrNotReady = errors.New("not ready")
 var NoMatchingDeviceTypeFound = errors.New("unable to find matching device type")
+var ErrNotSupported = errors.New("not supported by zwave controller")
@@ -0,0 +1,65 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package model
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CanonicalTypeMappingKey normalizes mapping keys to the decimal form <manufacturer>.<product type>.<product id>[.<endpoint>].
+// Accepted parts are decimal (271.4609.4096), hex (0x010f.0x1201.0x1000) and, as product id,
+// zwave2mqtt device ids (<manufacturer>-<product id>-<product type>, e.g. 0x010f.0x1201.271-4096-4609).
+// Keys in other formats are returned trimmed.
+func CanonicalTypeMappingKey(key string) string {
+	key = strings.TrimSpace(key)
+	parts := strings.Split(key, ".")
+	if len(parts) < 3 || len(parts) > 4 {
+		return key
+	}
+	result := make([]string, len(parts))
+	for i, part := range parts {
+		if i == 2 {
+			if deviceIdParts := strings.Split(part, "-"); len(deviceIdParts) == 3 {
+				part = deviceIdParts[1]
+			}
+		}
+		number, err := ParseTypeMappingKeyPart(part)
+		if err != nil {
+			return key
+		}
+		result[i] = strconv.FormatUint(number, 10)
+	}
+	return strings.Join(result, ".")
+}
+
+// ParseTypeMappingKeyPart parses a decimal or hex (0x...) manufacturer id, product type or product id
+func ParseTypeMappingKeyPart(part string) (uint64, error) {
+	if strings.HasPrefix(part, "0x") || strings.HasPrefix(part, "0X") {
+		return strconv.ParseUint(part[2:], 16, 64)
+	}
+	return strconv.ParseUint(part, 10, 64)
+}
+
+func (this DeviceInfo) GetCanonicalTypeMappingKey() string {
+	return CanonicalTypeMappingKey(this.GetTypeMappingKey())
+}
+
+// TypeMappingKeysMatch compares the canonical forms of the mapping keys
+func TypeMappingKeysMatch(a string, b string) bool {
+	return CanonicalTypeMappingKey(a) == CanonicalTypeMappingKey(b)
+}
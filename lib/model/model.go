@@ -23,21 +23,96 @@ import (
 )
 
 type DeviceInfo struct {
-	NodeId         int64
-	Name           string
-	Manufacturer   string
-	ManufacturerId string
-	Product        string
-	ProductType    string
-	ProductId      string
-	Values         map[string]Value
-	Statistics     Statistics
+	NodeId          int64
+	Name            string
+	Location        string
+	Manufacturer    string
+	ManufacturerId  string
+	Product         string
+	ProductType     string
+	ProductId       string
+	FirmwareVersion string //reported by zwavejs2mqtt
+	Values          map[string]Value
+	FilteredValues  map[string]Value //values hidden by filter rules; still used by device type rules
+	Statistics      Statistics
+	Endpoints       []int64 //multi-channel endpoints reported by the controller
+	Endpoint        int64   //>0 if this DeviceInfo describes a single endpoint of a node
+	Topology        Topology
+	Groups          []AssociationGroup //reported by zwavejs2mqtt
+	Status          string             //Alive, Asleep, Dead or Unknown; reported by zwavejs2mqtt
+	IsListening     bool               //false for nodes which sleep (battery powered); reported by zwavejs2mqtt
+}
+
+const (
+	NodeStatusAlive  = "Alive"
+	NodeStatusAsleep = "Asleep"
+	NodeStatusDead   = "Dead"
+)
+
+// CanBePolled is false for sleeping and dead nodes
+func (this DeviceInfo) CanBePolled() bool {
+	return this.IsListening && this.Status != NodeStatusAsleep && this.Status != NodeStatusDead
+}
+
+// Topology contains the routing information of a node as reported by the controller
+type Topology struct {
+	Neighbors        []int64
+	IsRouting        bool
+	Rssi             *float64
+	LastWorkingRoute *Route
+}
+
+// AssociationGroup describes an association group of a node or one of its endpoints
+type AssociationGroup struct {
+	GroupId      int64  `json:"groupId"`
+	Endpoint     int64  `json:"endpoint"`
+	Label        string `json:"label"`
+	MaxNodes     int64  `json:"maxNodes"`
+	IsLifeline   bool   `json:"isLifeline"`
+	MultiChannel bool   `json:"multiChannel"`
+}
+
+// AssociationAddress is an association source or target; an endpoint creates a multi-channel association
+type AssociationAddress struct {
+	NodeId   int64  `json:"nodeId"`
+	Endpoint *int64 `json:"endpoint,omitempty"`
+}
+
+// Association is a member of the association group GroupId of the source endpoint Endpoint
+type Association struct {
+	Endpoint       int64  `json:"endpoint"`
+	GroupId        int64  `json:"groupId"`
+	NodeId         int64  `json:"nodeId"`
+	TargetEndpoint *int64 `json:"targetEndpoint,omitempty"`
+}
+
+type Route struct {
+	Repeaters    []int64   `json:"repeaters"`
+	Rssi         *float64  `json:"rssi,omitempty"`
+	RepeaterRssi []float64 `json:"repeaterRSSI,omitempty"`
+	DataRate     int64     `json:"protocolDataRate,omitempty"`
 }
 
 func (this DeviceInfo) GetTypeMappingKey() string {
-	return this.ManufacturerId + "." + this.ProductType + "." + this.ProductId
+	key := this.ManufacturerId + "." + this.ProductType + "." + this.ProductId
+	if this.Endpoint > 0 {
+		key = key + "." + strconv.FormatInt(this.Endpoint, 10)
+	}
+	return key
 }
 
+// GetRawDeviceId returns the device id without prefix: <nodeId> or <nodeId>.<endpoint>
+func (this DeviceInfo) GetRawDeviceId() string {
+	id := strconv.FormatInt(this.NodeId, 10)
+	if this.Endpoint > 0 {
+		id = id + "." + strconv.FormatInt(this.Endpoint, 10)
+	}
+	return id
+}
+
+// DefaultControllerNodeId is the node id of the controller if the zwave client does not report it
+const DefaultControllerNodeId int64 = 1
+
 func (this DeviceInfo) IsValid() bool {
 	if this.NodeId == 0 || this.NodeId == 1 {
 		return false
@@ -98,6 +173,13 @@ type Value struct {
 	Values            interface{} `json:"values"`
 	Value             interface{} `json:"value"`
 	LastUpdate        int64       `json:"lastUpdate"`
+	Property          interface{} `json:"property,omitempty"`
+	PropertyKey       interface{} `json:"propertyKey,omitempty"`
+	Min               interface{} `json:"min,omitempty"`
+	Max               interface{} `json:"max,omitempty"`
+	Default           interface{} `json:"default,omitempty"`
+	Unit              string      `json:"unit,omitempty"`
+	States            interface{} `json:"states,omitempty"`
 }
 
 func (this Value) GetServiceId(get bool) string {
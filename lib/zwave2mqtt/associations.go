@@ -0,0 +1,31 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zwave2mqtt
+
+import "github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+
+func (this *Client) GetAssociations(_ int64) ([]model.Association, error) {
+	return nil, model.ErrNotSupported
+}
+
+func (this *Client) AddAssociations(_ model.AssociationAddress, _ int64, _ []model.AssociationAddress) (interface{}, error) {
+	return nil, model.ErrNotSupported
+}
+
+func (this *Client) RemoveAssociations(_ model.AssociationAddress, _ int64, _ []model.AssociationAddress) (interface{}, error) {
+	return nil, model.ErrNotSupported
+}
@@ -127,6 +127,11 @@ func (this *Client) startDefaultListener() error {
 	return nil
 }
 
+// GetControllerNodeId returns model.DefaultControllerNodeId; zwave2mqtt does not report the controller node
+func (this *Client) GetControllerNodeId() int64 {
+	return model.DefaultControllerNodeId
+}
+
 func (this *Client) RequestDeviceInfoUpdate() error {
 	return this.SendZwayCommand(GetNodesCommandTopic, []interface{}{})
 }
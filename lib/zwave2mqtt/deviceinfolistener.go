@@ -35,34 +35,12 @@ func (this *Client) startNodeCommandListener() error {
 	token := this.mqtt.Subscribe(this.apiTopic+GetNodesCommandTopic, 2, func(client paho.Client, message paho.Message) {
 		if this.deviceInfoListener != nil {
 			slog.Debug("getNodes response", "topic", message.Topic(), "payload", string(message.Payload()))
-			wrapper := NodeInfoResultWrapper{}
-			err := json.Unmarshal(message.Payload(), &wrapper)
+			deviceInfos, huskIds, err := ParseGetNodesResult(message.Payload())
 			if err != nil {
 				slog.Error("unable to unmarshal getNodes wrapper", "error", err)
 				this.ForwardError("unable to unmarshal getNodes wrapper: " + err.Error())
 				return
 			}
-			deviceInfos := []model.DeviceInfo{}
-			huskIds := []int64{}
-			for _, node := range wrapper.Result {
-				deviceInfo := model.DeviceInfo{
-					NodeId:         node.NodeId,
-					Name:           node.Name,
-					Manufacturer:   node.Manufacturer,
-					ManufacturerId: node.ManufacturerId,
-					Product:        node.Product,
-					ProductType:    node.ProductType,
-					ProductId:      node.DeviceId,
-					Values:         node.Values,
-				}
-				if deviceInfo.IsValid() {
-					deviceInfos = append(deviceInfos, deviceInfo)
-				} else if deviceInfo.IsHusk() {
-					huskIds = append(huskIds, deviceInfo.NodeId)
-				} else {
-					slog.Debug("IGNORE", "deviceInfo", fmt.Sprintf("%#v", deviceInfo))
-				}
-			}
 			this.deviceInfoListener(deviceInfos, huskIds, true, true)
 		}
 	})
@@ -74,6 +52,38 @@ func (this *Client) startNodeCommandListener() error {
 	return nil
 }
 
+// ParseGetNodesResult parses a getNodes api response into valid device infos and husk node ids
+func ParseGetNodesResult(payload []byte) (deviceInfos []model.DeviceInfo, huskIds []int64, err error) {
+	wrapper := NodeInfoResultWrapper{}
+	err = json.Unmarshal(payload, &wrapper)
+	if err != nil {
+		return nil, nil, err
+	}
+	deviceInfos = []model.DeviceInfo{}
+	huskIds = []int64{}
+	for _, node := range wrapper.Result {
+		deviceInfo := model.DeviceInfo{
+			NodeId:         node.NodeId,
+			Name:           node.Name,
+			Location:       node.Loc,
+			Manufacturer:   node.Manufacturer,
+			ManufacturerId: node.ManufacturerId,
+			Product:        node.Product,
+			ProductType:    node.ProductType,
+			ProductId:      node.DeviceId,
+			Values:         node.Values,
+		}
+		if deviceInfo.IsValid() {
+			deviceInfos = append(deviceInfos, deviceInfo)
+		} else if deviceInfo.IsHusk() {
+			huskIds = append(huskIds, deviceInfo.NodeId)
+		} else {
+			slog.Debug("IGNORE", "deviceInfo", fmt.Sprintf("%#v", deviceInfo))
+		}
+	}
+	return deviceInfos, huskIds, nil
+}
+
 func (this *Client) startNodeEventListener() error {
 	if this.networkEventsTopic == "" || this.networkEventsTopic == "-" {
 		slog.Warn("no zwave network event topic configured --> no live device availability check")
@@ -123,6 +133,7 @@ func (this *Client) startNodeEventListener() error {
 			deviceInfo := model.DeviceInfo{
 				NodeId:         int64(nodeIdF),
 				Name:           info.Name,
+				Location:       info.Loc,
 				Manufacturer:   info.Manufacturer,
 				ManufacturerId: info.ManufacturerId,
 				Product:        info.Product,
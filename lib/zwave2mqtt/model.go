@@ -85,5 +85,6 @@ type NodeInfo struct {
 	ProductId      string                 `json:"productid"`
 	Type           string                 `json:"type"`
 	Name           string                 `json:"name"`
+	Loc            string                 `json:"loc"`
 	Values         map[string]model.Value `json:"values"`
 }
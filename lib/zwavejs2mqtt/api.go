@@ -0,0 +1,210 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zwavejs2mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+const ApiResponseTimeout = 30 * time.Second
+
+var ErrApiTimeout = errors.New("timeout while waiting for zwavejs2mqtt api response")
+
+type ApiResult struct {
+	ResultWrapper
+	Result json.RawMessage `json:"result"`
+}
+
+type apiCall struct {
+	args     string
+	response chan ApiResult
+}
+
+// CallApi sends a command to the zwavejs2mqtt api and waits for the response.
+// responses are matched by command and args; the result is returned as raw json
+func (this *Client) CallApi(command string, args []interface{}) (result json.RawMessage, err error) {
+	return this.CallApiWithTimeout(command, args, this.apiResponseTimeout)
+}
+
+// CallApiWithTimeout is CallApi with a custom response timeout, for long-running gateway operations
+func (this *Client) CallApiWithTimeout(command string, args []interface{}, timeout time.Duration) (result json.RawMessage, err error) {
+	if args == nil {
+		args = []interface{}{}
+	}
+	err = this.ensureApiResponseSubscription(command)
+	if err != nil {
+		return result, err
+	}
+	call := apiCall{args: normalizeArgs(args), response: make(chan ApiResult, 1)}
+	this.addApiCall(command, call)
+	defer this.removeApiCall(command, call)
+
+	err = this.SendZwayCommand(command, args)
+	if err != nil {
+		return result, err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case response := <-call.response:
+		if !response.Success {
+			return response.Result, fmt.Errorf("zwavejs2mqtt api %v: %v", command, response.Message)
+		}
+		return response.Result, nil
+	case <-timer.C:
+		return result, fmt.Errorf("%w: %v", ErrApiTimeout, command)
+	}
+}
+
+// CallApiAndDecode calls CallApi and unmarshals the result into a generic value
+func (this *Client) CallApiAndDecode(command string, args []interface{}) (result interface{}, err error) {
+	return decodeApiResult(this.CallApi(command, args))
+}
+
+func decodeApiResult(raw json.RawMessage, err error) (result interface{}, _ error) {
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	err = json.Unmarshal(raw, &result)
+	return result, err
+}
+
+func (this *Client) ensureApiResponseSubscription(command string) error {
+	this.apiMux.Lock()
+	defer this.apiMux.Unlock()
+	if this.apiSubscriptions[command] {
+		return nil
+	}
+	err := this.subscribeApiResponse(command)
+	if err != nil {
+		return err
+	}
+	this.apiSubscriptions[command] = true
+	return nil
+}
+
+// used on (re)connect to restore subscriptions of previously used api commands
+func (this *Client) startApiResponseListener() error {
+	this.apiMux.Lock()
+	defer this.apiMux.Unlock()
+	for command := range this.apiSubscriptions {
+		err := this.subscribeApiResponse(command)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (this *Client) subscribeApiResponse(command string) error {
+	if !this.mqtt.IsConnected() {
+		slog.Warn("mqtt client not connected")
+		return errors.New("mqtt client not connected")
+	}
+	topic := this.apiTopic + command
+	slog.Info("subscribe", "topic", topic)
+	token := this.mqtt.Subscribe(topic, 2, func(client paho.Client, message paho.Message) {
+		slog.Debug("api response", "topic", message.Topic(), "payload", string(message.Payload()))
+		response := ApiResult{}
+		err := json.Unmarshal(message.Payload(), &response)
+		if err != nil {
+			slog.Error("unable to unmarshal api response", "topic", message.Topic(), "error", err)
+			return
+		}
+		this.resolveApiCall(command, response)
+	})
+	if token.Wait() && token.Error() != nil {
+		slog.Error("Error on Subscribe", "topic", topic, "error", token.Error())
+		this.ForwardError("Error on Subscribe: " + token.Error().Error())
+		return token.Error()
+	}
+	return nil
+}
+
+func (this *Client) addApiCall(command string, call apiCall) {
+	this.apiMux.Lock()
+	defer this.apiMux.Unlock()
+	this.apiCalls[command] = append(this.apiCalls[command], call)
+}
+
+func (this *Client) removeApiCall(command string, call apiCall) {
+	this.apiMux.Lock()
+	defer this.apiMux.Unlock()
+	calls := this.apiCalls[command]
+	for i, c := range calls {
+		if c.response == call.response {
+			this.apiCalls[command] = append(calls[:i], calls[i+1:]...)
+			return
+		}
+	}
+}
+
+// delivers the response to the oldest call with matching args, or to the oldest call if the gateway did not echo the args
+func (this *Client) resolveApiCall(command string, response ApiResult) {
+	this.apiMux.Lock()
+	defer this.apiMux.Unlock()
+	calls := this.apiCalls[command]
+	if len(calls) == 0 {
+		return
+	}
+	index := 0
+	if response.Args != nil {
+		args := normalizeArgs(response.Args)
+		index = -1
+		for i, call := range calls {
+			if call.args == args {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			slog.Debug("ignore api response without matching call", "command", command, "args", args)
+			return
+		}
+	}
+	call := calls[index]
+	this.apiCalls[command] = append(calls[:index], calls[index+1:]...)
+	call.response <- response
+}
+
+// json representation with sorted object keys, independent of the go types used to create the args
+func normalizeArgs(args []interface{}) string {
+	temp, err := json.Marshal(args)
+	if err != nil {
+		return ""
+	}
+	var generic interface{}
+	err = json.Unmarshal(temp, &generic)
+	if err != nil {
+		return ""
+	}
+	temp, err = json.Marshal(generic)
+	if err != nil {
+		return ""
+	}
+	return string(temp)
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zwavejs2mqtt
+
+import (
+	"encoding/json"
+
+	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
+)
+
+// GetAssociations returns the members of all association groups of the node and its endpoints
+func (this *Client) GetAssociations(nodeId int64) (result []model.Association, err error) {
+	raw, err := this.CallApi("/getAssociations", []interface{}{nodeId})
+	if err != nil {
+		return result, err
+	}
+	result = []model.Association{}
+	if len(raw) == 0 {
+		return result, nil
+	}
+	err = json.Unmarshal(raw, &result)
+	return result, err
+}
+
+func (this *Client) AddAssociations(source model.AssociationAddress, groupId int64, targets []model.AssociationAddress) (interface{}, error) {
+	return this.CallApiAndDecode("/addAssociations", []interface{}{source, groupId, targets})
+}
+
+func (this *Client) RemoveAssociations(source model.AssociationAddress, groupId int64, targets []model.AssociationAddress) (interface{}, error) {
+	return this.CallApiAndDecode("/removeAssociations", []interface{}{source, groupId, targets})
+}
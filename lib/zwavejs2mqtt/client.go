@@ -25,6 +25,8 @@ import (
 	"log/slog"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/configuration"
@@ -35,29 +37,48 @@ import (
 type DeviceInfoListener = func(nodes []model.DeviceInfo, huskIds []int64, withValues bool, allKnownDevices bool)
 type ValueEventListener = func(value model.Value)
 type DeviceStateListener = func(nodeId int64, online bool) error
+type ControllerEventListener = func(event string, data []interface{})
+type NodeEventListener = func(event string, nodeId int64, data []interface{})
 
 const GetNodesCommandTopic = "/getNodes"
 const NodeAvailableTopic = "/node_alive"
 
 type Client struct {
-	mqtt                paho.Client
-	debug               bool
-	apiTopic            string
-	networkEventsTopic  string
-	deviceStateTopic    string
-	deviceInfoListener  DeviceInfoListener
-	valueEventListener  ValueEventListener
-	deviceStateListener DeviceStateListener
-	forwardErrorMsg     func(msg string)
+	mqtt                    paho.Client
+	debug                   bool
+	apiTopic                string
+	networkEventsTopic      string
+	deviceStateTopic        string
+	controllerEventsTopic   string
+	nodeEventsTopic         string
+	deviceInfoListener      DeviceInfoListener
+	valueEventListener      ValueEventListener
+	deviceStateListener     DeviceStateListener
+	controllerEventListener ControllerEventListener
+	nodeEventListener       NodeEventListener
+	forwardErrorMsg         func(msg string)
+	apiResponseTimeout      time.Duration
+	apiSubscriptions        map[string]bool
+	apiCalls                map[string][]apiCall
+	apiMux                  sync.Mutex
+	controllerNodeId        atomic.Int64 //set by getNodes responses; 0 until known
 }
 
 func New(config configuration.Config, ctx context.Context) (*Client, error) {
 	slog.Info("start zwavejs2mqtt client")
 	client := &Client{
-		deviceStateTopic:   config.ZwaveMqttDeviceStateTopic,
-		apiTopic:           config.ZwaveMqttApiTopic,
-		networkEventsTopic: config.ZwaveNetworkEventsTopic,
-		debug:              config.Debug,
+		deviceStateTopic:      config.ZwaveMqttDeviceStateTopic,
+		apiTopic:              config.ZwaveMqttApiTopic,
+		networkEventsTopic:    config.ZwaveNetworkEventsTopic,
+		controllerEventsTopic: config.ZwaveControllerEventsTopic,
+		nodeEventsTopic:       config.ZwaveNodeEventsTopic,
+		debug:                 config.Debug,
+		apiResponseTimeout:    config.ZwaveApiTimeout.GetDuration(),
+		apiSubscriptions:      map[string]bool{},
+		apiCalls:              map[string][]apiCall{},
+	}
+	if client.apiResponseTimeout == 0 {
+		client.apiResponseTimeout = ApiResponseTimeout
 	}
 	options := paho.NewClientOptions().
 		SetPassword(config.ZwaveMqttPw).
@@ -116,6 +137,14 @@ func (this *Client) SetDeviceStatusListener(listener func(nodeId int64, online b
 	this.deviceStateListener = listener
 }
 
+func (this *Client) SetControllerEventListener(listener func(event string, data []interface{})) {
+	this.controllerEventListener = listener
+}
+
+func (this *Client) SetNodeEventListener(listener func(event string, nodeId int64, data []interface{})) {
+	this.nodeEventListener = listener
+}
+
 func (this *Client) startDefaultListener() error {
 	err := this.startNodeCommandListener()
 	if err != nil {
@@ -129,10 +158,30 @@ func (this *Client) startDefaultListener() error {
 	if err != nil {
 		return err
 	}
+	err = this.startControllerEventListener()
+	if err != nil {
+		return err
+	}
+	err = this.startNodeStatusEventListener()
+	if err != nil {
+		return err
+	}
+	err = this.startApiResponseListener()
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
+// GetControllerNodeId returns the node id of the controller as reported by the last getNodes response
+func (this *Client) GetControllerNodeId() int64 {
+	if id := this.controllerNodeId.Load(); id != 0 {
+		return id
+	}
+	return model.DefaultControllerNodeId
+}
+
 func (this *Client) RequestDeviceInfoUpdate() error {
 	return this.SendZwayCommand(GetNodesCommandTopic, []interface{}{})
 }
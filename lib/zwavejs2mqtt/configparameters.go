@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zwavejs2mqtt
+
+const ConfigurationCommandClass int64 = 112
+
+// partial parameters are addressed by their bit mask, which zwave-js uses as property key
+func configParameterValueId(nodeId int64, parameter int64, bitMask *int64) ValueID {
+	valueId := ValueID{
+		NodeId:       nodeId,
+		CommandClass: ConfigurationCommandClass,
+		Endpoint:     0,
+		Property:     parameter,
+	}
+	if bitMask != nil {
+		valueId.PropertyKey = *bitMask
+	}
+	return valueId
+}
+
+// GetConfigParameter reads the current parameter value from the device
+func (this *Client) GetConfigParameter(nodeId int64, parameter int64, bitMask *int64) (interface{}, error) {
+	return this.CallApiAndDecode("/pollValue", []interface{}{configParameterValueId(nodeId, parameter, bitMask)})
+}
+
+func (this *Client) SetConfigParameter(nodeId int64, parameter int64, bitMask *int64, value interface{}) (interface{}, error) {
+	return this.CallApiAndDecode("/writeValue", []interface{}{configParameterValueId(nodeId, parameter, bitMask), value})
+}
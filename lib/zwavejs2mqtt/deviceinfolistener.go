@@ -36,41 +36,14 @@ func (this *Client) startNodeCommandListener() error {
 	token := this.mqtt.Subscribe(this.apiTopic+GetNodesCommandTopic, 2, func(client paho.Client, message paho.Message) {
 		if this.deviceInfoListener != nil {
 			slog.Debug("getNodes response", "topic", message.Topic(), "payload", string(message.Payload()))
-			wrapper := NodeInfoResultWrapper{}
-			err := json.Unmarshal(message.Payload(), &wrapper)
+			deviceInfos, huskIds, controllerNodeId, err := ParseGetNodesResult(message.Payload())
 			if err != nil {
 				slog.Error("unable to unmarshal getNodes wrapper", "error", err)
 				this.ForwardError("unable to unmarshal getNodes wrapper: " + err.Error())
 				return
 			}
-			deviceInfos := []model.DeviceInfo{}
-			huskIds := []int64{}
-			for _, node := range wrapper.Result {
-				deviceInfo := model.DeviceInfo{
-					NodeId:         node.Id,
-					Name:           node.Name,
-					Manufacturer:   node.Manufacturer,
-					ManufacturerId: strconv.FormatInt(node.ManufacturerId, 10),
-					Product:        node.ProductDescription,
-					ProductType:    strconv.FormatInt(node.ProductType, 10),
-					ProductId:      strconv.FormatInt(node.ProductId, 10),
-					Values:         transformValues(node.Values),
-					Statistics: model.Statistics{
-						CommandTx:         node.Statistics.CommandTx,
-						CommandsRX:        node.Statistics.CommandsRX,
-						CommandsDroppedRX: node.Statistics.CommandsDroppedRX,
-						CommandsDroppedTX: node.Statistics.CommandsDroppedTX,
-						TimeoutResponse:   node.Statistics.TimeoutResponse,
-						Rtt:               node.Statistics.Rtt,
-					},
-				}
-				if deviceInfo.IsValid() {
-					deviceInfos = append(deviceInfos, deviceInfo)
-				} else if deviceInfo.IsHusk() {
-					huskIds = append(huskIds, deviceInfo.NodeId)
-				} else {
-					slog.Debug("IGNORE", "deviceInfo", fmt.Sprintf("%#v", deviceInfo))
-				}
+			if controllerNodeId != 0 {
+				this.controllerNodeId.Store(controllerNodeId)
 			}
 			this.deviceInfoListener(deviceInfos, huskIds, true, true)
 		}
@@ -83,6 +56,62 @@ func (this *Client) startNodeCommandListener() error {
 	return nil
 }
 
+// ParseGetNodesResult parses a getNodes api response into valid device infos and husk node ids
+// controllerNodeId is 0 if the response contains no node flagged as controller
+func ParseGetNodesResult(payload []byte) (deviceInfos []model.DeviceInfo, huskIds []int64, controllerNodeId int64, err error) {
+	wrapper := NodeInfoResultWrapper{}
+	err = json.Unmarshal(payload, &wrapper)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	deviceInfos = []model.DeviceInfo{}
+	huskIds = []int64{}
+	for _, node := range wrapper.Result {
+		if node.IsControllerNode {
+			controllerNodeId = node.Id
+			continue
+		}
+		deviceInfo := model.DeviceInfo{
+			NodeId:          node.Id,
+			Name:            node.Name,
+			Location:        node.Loc,
+			Manufacturer:    node.Manufacturer,
+			ManufacturerId:  strconv.FormatInt(node.ManufacturerId, 10),
+			Product:         node.ProductDescription,
+			ProductType:     strconv.FormatInt(node.ProductType, 10),
+			ProductId:       strconv.FormatInt(node.ProductId, 10),
+			FirmwareVersion: node.FirmwareVersion,
+			Values:          transformValues(node.Values),
+			Statistics: model.Statistics{
+				CommandTx:         node.Statistics.CommandTx,
+				CommandsRX:        node.Statistics.CommandsRX,
+				CommandsDroppedRX: node.Statistics.CommandsDroppedRX,
+				CommandsDroppedTX: node.Statistics.CommandsDroppedTX,
+				TimeoutResponse:   node.Statistics.TimeoutResponse,
+				Rtt:               node.Statistics.Rtt,
+			},
+			Endpoints: node.EndpointIndizes,
+			Topology: model.Topology{
+				Neighbors:        node.Neighbors,
+				IsRouting:        node.IsRouting,
+				Rssi:             node.Statistics.Rssi,
+				LastWorkingRoute: node.Statistics.Lwr,
+			},
+			Groups:      transformGroups(node.Groups),
+			Status:      node.Status,
+			IsListening: node.IsListening || isFrequentListening(node.IsFrequentListening),
+		}
+		if deviceInfo.IsValid() {
+			deviceInfos = append(deviceInfos, deviceInfo)
+		} else if deviceInfo.IsHusk() {
+			huskIds = append(huskIds, deviceInfo.NodeId)
+		} else {
+			slog.Debug("IGNORE", "deviceInfo", fmt.Sprintf("%#v", deviceInfo))
+		}
+	}
+	return deviceInfos, huskIds, controllerNodeId, nil
+}
+
 func (this *Client) startNodeEventListener() error {
 	if this.networkEventsTopic == "" || this.networkEventsTopic == "-" {
 		slog.Warn("no zwave network event topic configured --> no live device availability check")
@@ -110,6 +139,7 @@ func (this *Client) startNodeEventListener() error {
 					deviceInfo := model.DeviceInfo{
 						NodeId:         info.Id,
 						Name:           info.Name,
+						Location:       info.Loc,
 						Manufacturer:   info.Manufacturer,
 						ManufacturerId: strconv.FormatInt(info.ManufacturerId, 10),
 						Product:        info.ProductDescription,
@@ -133,3 +163,14 @@ func (this *Client) startNodeEventListener() error {
 	}
 	return nil
 }
+
+// FLiRS nodes wake up on a beam and can be reached like listening nodes
+func isFrequentListening(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zwavejs2mqtt
+
+// BeginFirmwareUpdate hands the firmware file to the gateway; progress and result are published as node events.
+// the file content is sent as list of bytes, which the gateway converts to a buffer
+func (this *Client) BeginFirmwareUpdate(nodeId int64, fileName string, data []byte, target int64) (interface{}, error) {
+	content := make([]int, len(data))
+	for i, b := range data {
+		content[i] = int(b)
+	}
+	return this.CallApiAndDecode("/beginFirmwareUpdate", []interface{}{nodeId, fileName, content, target})
+}
+
+func (this *Client) AbortFirmwareUpdate(nodeId int64) (interface{}, error) {
+	return this.CallApiAndDecode("/abortFirmwareUpdate", []interface{}{nodeId})
+}
+
+// RefreshInfo lets the gateway re-interview the node
+func (this *Client) RefreshInfo(nodeId int64) (interface{}, error) {
+	return this.CallApiAndDecode("/refreshInfo", []interface{}{nodeId})
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zwavejs2mqtt
+
+import "time"
+
+// a health check runs rounds of pings and power level tests and may take minutes
+const LifelineHealthCheckTimeout = 10 * time.Minute
+
+func (this *Client) PingNode(nodeId int64) (interface{}, error) {
+	return this.CallApiAndDecode("/pingNode", []interface{}{nodeId})
+}
+
+// RefreshValues lets the gateway poll all values of the node
+func (this *Client) RefreshValues(nodeId int64) (interface{}, error) {
+	return this.CallApiAndDecode("/refreshValues", []interface{}{nodeId})
+}
+
+// CheckLifelineHealth tests the connection between node and controller; rounds <= 0 uses the gateway default
+func (this *Client) CheckLifelineHealth(nodeId int64, rounds int64) (interface{}, error) {
+	args := []interface{}{nodeId}
+	if rounds > 0 {
+		args = append(args, rounds)
+	}
+	return decodeApiResult(this.CallApiWithTimeout("/checkLifelineHealth", args, LifelineHealthCheckTimeout))
+}
@@ -17,6 +17,8 @@
 package zwavejs2mqtt
 
 import (
+	"encoding/json"
+
 	"github.com/SENERGY-Platform/mgw-zwave-dc/lib/model"
 	"strconv"
 	"strings"
@@ -102,25 +104,63 @@ type NodeAvailableMessage struct {
 	}
 */
 type NodeInfo struct {
-	Id                 int64                `json:"id"`
-	DeviceId           string               `json:"deviceId"`
-	Manufacturer       string               `json:"manufacturer"`
-	ManufacturerId     int64                `json:"manufacturerId"`
-	ProductDescription string               `json:"productDescription"`
-	ProductType        int64                `json:"productType"`
-	ProductId          int64                `json:"productId"`
-	Name               string               `json:"name"`
-	Values             map[string]NodeValue `json:"values"`
-	Statistics         Statistics           `json:"statistics"`
+	Id                  int64                `json:"id"`
+	DeviceId            string               `json:"deviceId"`
+	Manufacturer        string               `json:"manufacturer"`
+	ManufacturerId      int64                `json:"manufacturerId"`
+	ProductDescription  string               `json:"productDescription"`
+	ProductType         int64                `json:"productType"`
+	ProductId           int64                `json:"productId"`
+	FirmwareVersion     string               `json:"firmwareVersion"`
+	Name                string               `json:"name"`
+	Loc                 string               `json:"loc"`
+	Values              map[string]NodeValue `json:"values"`
+	Statistics          Statistics           `json:"statistics"`
+	EndpointIndizes     []int64              `json:"endpointIndizes"`
+	Neighbors           []int64              `json:"neighbors"`
+	IsRouting           bool                 `json:"isRouting"`
+	Groups              []NodeGroup          `json:"groups"`
+	Status              string               `json:"status"`
+	IsListening         bool                 `json:"isListening"`
+	IsFrequentListening interface{}          `json:"isFrequentListening"` //false or wakeup interval ("250ms", "1000ms")
+	IsControllerNode    bool                 `json:"isControllerNode"`
+}
+
+/*
+{"text":"Lifeline","value":1,"endpoint":0,"maxNodes":5,"isLifeline":true,"multiChannel":true}
+*/
+type NodeGroup struct {
+	Text         string `json:"text"`
+	Value        int64  `json:"value"`
+	Endpoint     int64  `json:"endpoint"`
+	MaxNodes     int64  `json:"maxNodes"`
+	IsLifeline   bool   `json:"isLifeline"`
+	MultiChannel bool   `json:"multiChannel"`
+}
+
+func transformGroups(groups []NodeGroup) (result []model.AssociationGroup) {
+	for _, group := range groups {
+		result = append(result, model.AssociationGroup{
+			GroupId:      group.Value,
+			Endpoint:     group.Endpoint,
+			Label:        group.Text,
+			MaxNodes:     group.MaxNodes,
+			IsLifeline:   group.IsLifeline,
+			MultiChannel: group.MultiChannel,
+		})
+	}
+	return result
 }
 
 type Statistics struct {
-	CommandTx         float64 `json:"commandsTX"`
-	CommandsRX        float64 `json:"commandsRX"`
-	CommandsDroppedRX float64 `json:"commandsDroppedRX"`
-	CommandsDroppedTX float64 `json:"commandsDroppedTX"`
-	TimeoutResponse   float64 `json:"timeoutResponse"`
-	Rtt               float64 `json:"rtt"`
+	CommandTx         float64      `json:"commandsTX"`
+	CommandsRX        float64      `json:"commandsRX"`
+	CommandsDroppedRX float64      `json:"commandsDroppedRX"`
+	CommandsDroppedTX float64      `json:"commandsDroppedTX"`
+	TimeoutResponse   float64      `json:"timeoutResponse"`
+	Rtt               float64      `json:"rtt"`
+	Rssi              *float64     `json:"rssi"`
+	Lwr               *model.Route `json:"lwr"` //last working route
 }
 
 /*
@@ -160,6 +200,13 @@ type NodeValue struct {
 	Values           interface{} `json:"values"`
 	Value            interface{} `json:"value"`
 	LastUpdate       int64       `json:"lastUpdate"`
+	Property         interface{} `json:"property"`
+	PropertyKey      interface{} `json:"propertyKey"`
+	Min              interface{} `json:"min"`
+	Max              interface{} `json:"max"`
+	Default          interface{} `json:"default"`
+	Unit             string      `json:"unit"`
+	States           interface{} `json:"states"`
 }
 
 func transformValues(values map[string]NodeValue) (result map[string]model.Value) {
@@ -205,6 +252,13 @@ func transformValue(value NodeValue) (result model.Value) {
 		Value:             value.Value,
 		LastUpdate:        value.LastUpdate,
 		ComputedServiceId: strings.TrimPrefix(value.Id, strconv.FormatInt(value.NodeId, 10)+"-"),
+		Property:          value.Property,
+		PropertyKey:       value.PropertyKey,
+		Min:               value.Min,
+		Max:               value.Max,
+		Default:           value.Default,
+		Unit:              value.Unit,
+		States:            value.States,
 	}
 }
 
@@ -212,3 +266,11 @@ type DeviceStateMsg struct {
 	Status string `json:"status"`
 	NodeId int64  `json:"nodeId"`
 }
+
+type ControllerEventMessage struct {
+	Data []interface{} `json:"data"`
+}
+
+type NodeEventMessage struct {
+	Data []json.RawMessage `json:"data"`
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zwavejs2mqtt
+
+// WriteMulticast writes the value to all nodes with one multicast frame; localValueId is the value id without node id (<cc>-<endpoint>-<property>[-<key>])
+func (this *Client) WriteMulticast(nodeIds []int64, localValueId string, value interface{}) error {
+	valueId, err := parseLocalValueId(localValueId)
+	if err != nil {
+		return err
+	}
+	_, err = this.CallApi("/writeMulticast", []interface{}{nodeIds, valueId, value})
+	return err
+}
+
+// WriteBroadcast writes the value to all nodes of the network
+func (this *Client) WriteBroadcast(localValueId string, value interface{}) error {
+	valueId, err := parseLocalValueId(localValueId)
+	if err != nil {
+		return err
+	}
+	_, err = this.CallApi("/writeBroadcast", []interface{}{valueId, value})
+	return err
+}
+
+// multicast value ids have no node id
+func parseLocalValueId(localValueId string) (valueId ValueID, err error) {
+	valueId, err = parseValueId("0-" + localValueId)
+	if err != nil {
+		return valueId, err
+	}
+	valueId.NodeId = 0
+	return valueId, nil
+}
@@ -0,0 +1,109 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zwavejs2mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+func (this *Client) StartInclusion(strategy int64, options map[string]interface{}) (interface{}, error) {
+	args := []interface{}{strategy}
+	if options != nil {
+		args = append(args, options)
+	}
+	return this.CallApiAndDecode("/startInclusion", args)
+}
+
+func (this *Client) StopInclusion() (interface{}, error) {
+	return this.CallApiAndDecode("/stopInclusion", []interface{}{})
+}
+
+func (this *Client) StartExclusion() (interface{}, error) {
+	return this.CallApiAndDecode("/startExclusion", []interface{}{})
+}
+
+func (this *Client) StopExclusion() (interface{}, error) {
+	return this.CallApiAndDecode("/stopExclusion", []interface{}{})
+}
+
+func (this *Client) HealNetwork() (interface{}, error) {
+	return this.CallApiAndDecode("/beginHealingNetwork", []interface{}{})
+}
+
+func (this *Client) HealNode(nodeId int64) (interface{}, error) {
+	return this.CallApiAndDecode("/healNode", []interface{}{nodeId})
+}
+
+func (this *Client) RemoveFailedNode(nodeId int64) (interface{}, error) {
+	return this.CallApiAndDecode("/removeFailedNode", []interface{}{nodeId})
+}
+
+func (this *Client) ReplaceFailedNode(nodeId int64, strategy int64) (interface{}, error) {
+	return this.CallApiAndDecode("/replaceFailedNode", []interface{}{nodeId, strategy})
+}
+
+func (this *Client) SoftReset() (interface{}, error) {
+	return this.CallApiAndDecode("/softReset", []interface{}{})
+}
+
+// RefreshNeighbors lets the controller refresh the neighbor lists of all nodes; the result maps node ids to neighbor ids
+func (this *Client) RefreshNeighbors() (interface{}, error) {
+	return this.CallApiAndDecode("/refreshNeighbors", []interface{}{})
+}
+
+/*
+zwavejs2mqtt publishes controller events (if 'Send Zwave events' is enabled) as
+<prefix>/_EVENTS/ZWAVE_GATEWAY-<name>/controller/<event name>
+
+	{"data":["inclusion started", true]}
+*/
+func (this *Client) startControllerEventListener() error {
+	if this.controllerEventsTopic == "" || this.controllerEventsTopic == "-" {
+		slog.Info("no zwave controller event topic configured --> no controller status and inclusion events")
+		return nil
+	}
+	if !this.mqtt.IsConnected() {
+		slog.Warn("mqtt client not connected")
+		return errors.New("mqtt client not connected")
+	}
+	slog.Info("subscribe", "topic", this.controllerEventsTopic)
+	token := this.mqtt.Subscribe(this.controllerEventsTopic, 2, func(client paho.Client, message paho.Message) {
+		if this.controllerEventListener != nil {
+			slog.Debug("controller event", "topic", message.Topic(), "payload", string(message.Payload()))
+			wrapper := ControllerEventMessage{}
+			err := json.Unmarshal(message.Payload(), &wrapper)
+			if err != nil {
+				slog.Error("unable to unmarshal controller event", "error", err)
+				this.ForwardError("unable to unmarshal controller event: " + err.Error())
+				return
+			}
+			parts := strings.Split(message.Topic(), "/")
+			this.controllerEventListener(parts[len(parts)-1], wrapper.Data)
+		}
+	})
+	if token.Wait() && token.Error() != nil {
+		slog.Error("Error on Subscribe", "topic", this.controllerEventsTopic, "error", token.Error())
+		this.ForwardError("Error on Subscribe: " + token.Error().Error())
+		return token.Error()
+	}
+	return nil
+}
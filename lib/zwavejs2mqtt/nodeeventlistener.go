@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zwavejs2mqtt
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+/*
+zwavejs2mqtt publishes node events (if 'Send Zwave events' is enabled) as
+<prefix>/_EVENTS/ZWAVE_GATEWAY-<name>/node/<event name>
+the first data element is the node
+
+	{"data":[{"id":5, "name":"", ...}, 10, 120]}
+*/
+func (this *Client) startNodeStatusEventListener() error {
+	if this.nodeEventsTopic == "" || this.nodeEventsTopic == "-" {
+		slog.Info("no zwave node event topic configured --> no firmware update and interview events")
+		return nil
+	}
+	if !this.mqtt.IsConnected() {
+		slog.Warn("mqtt client not connected")
+		return errors.New("mqtt client not connected")
+	}
+	slog.Info("subscribe", "topic", this.nodeEventsTopic)
+	token := this.mqtt.Subscribe(this.nodeEventsTopic, 2, func(client paho.Client, message paho.Message) {
+		if this.nodeEventListener != nil {
+			slog.Debug("node event", "topic", message.Topic(), "payload", string(message.Payload()))
+			wrapper := NodeEventMessage{}
+			err := json.Unmarshal(message.Payload(), &wrapper)
+			if err != nil {
+				slog.Error("unable to unmarshal node event", "error", err)
+				this.ForwardError("unable to unmarshal node event: " + err.Error())
+				return
+			}
+			if len(wrapper.Data) == 0 {
+				slog.Warn("ignore node event without node", "topic", message.Topic())
+				return
+			}
+			node := NodeInfo{}
+			err = json.Unmarshal(wrapper.Data[0], &node)
+			if err != nil || node.Id == 0 {
+				slog.Warn("ignore node event without node", "topic", message.Topic(), "error", err)
+				return
+			}
+			data := []interface{}{}
+			for _, element := range wrapper.Data[1:] {
+				var value interface{}
+				err = json.Unmarshal(element, &value)
+				if err != nil {
+					slog.Error("unable to unmarshal node event", "error", err)
+					this.ForwardError("unable to unmarshal node event: " + err.Error())
+					return
+				}
+				data = append(data, value)
+			}
+			parts := strings.Split(message.Topic(), "/")
+			this.nodeEventListener(parts[len(parts)-1], node.Id, data)
+		}
+	})
+	if token.Wait() && token.Error() != nil {
+		slog.Error("Error on Subscribe", "topic", this.nodeEventsTopic, "error", token.Error())
+		this.ForwardError("Error on Subscribe: " + token.Error().Error())
+		return token.Error()
+	}
+	return nil
+}
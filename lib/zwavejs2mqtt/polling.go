@@ -0,0 +1,29 @@
+/*
+ * Copyright (c) 2023 InfAI (CC SES)
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *    http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package zwavejs2mqtt
+
+import "time"
+
+// PollValue lets the gateway read the value from the device; the new value is published as value event,
+// also if the response is not received within the timeout
+func (this *Client) PollValue(valueId string, timeout time.Duration) (interface{}, error) {
+	valueIdObject, err := parseValueId(valueId)
+	if err != nil {
+		return nil, err
+	}
+	return decodeApiResult(this.CallApiWithTimeout("/pollValue", []interface{}{valueIdObject}, timeout))
+}
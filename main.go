@@ -30,6 +30,14 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "generate-device-types" {
+		err := generateDeviceTypes(os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
 	configLocation := flag.String("config", "config.json", "configuration file")
 	flag.Parse()
 